@@ -7,7 +7,7 @@ if [[ -f coverage.txt ]]; then
 fi
 
 for d in $(go list ./... | grep -v vendor); do
-    go test -coverprofile=profile.out -coverpkg=./... -covermode=atomic "$d"
+    go test -race -coverprofile=profile.out -coverpkg=./... -covermode=atomic "$d"
     if [[ -f profile.out ]]; then
         cat profile.out >> coverage.txt
         rm profile.out
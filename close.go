@@ -0,0 +1,72 @@
+package parsehub
+
+import (
+	"context"
+)
+
+// Run which is not handled when client is closed
+type PendingRun struct {
+	RunToken     string
+	ProjectToken string
+
+	// Run is finished but its handler did not return before close context was done
+	Handling bool
+
+	// Error of ParseHub API which stopped watching of run, e.g. ErrUnauthorized
+	Err error
+}
+
+func pendingRunOf(run *Run, handling bool) PendingRun {
+	return PendingRun{
+		RunToken:     run.token,
+		ProjectToken: run.projectToken(),
+		Handling:     handling,
+	}
+}
+
+// Runs left unhandled by closed client. Other processes can pick them up by token.
+type CloseReport struct {
+	// Watched runs which are not finished or handled yet,
+	// including runs which watching was stopped by permanent error of ParseHub API
+	Pending []PendingRun
+
+	// Runs which handlers failed all attempts
+	DeadLetters []*DeadLetter
+}
+
+// Checks whether all runs are handled
+func (report *CloseReport) Empty() bool {
+	return len(report.Pending) == 0 && len(report.DeadLetters) == 0
+}
+
+// Stops accepting new watches and polling of watched runs,
+// then waits for running handlers until context is done. Handlers called by watcher,
+// webhook handler, LoadRunFromBytes, LoadRunFromRequest and Replay are waited for.
+// Returns report of runs left unhandled and context error if handlers did not finish in time.
+// Run.Wait, Replay, LoadRunFromBytes, LoadRunFromRequest and Project.RunWithHandlers
+// with handlers return ErrClosed after close.
+func (parsehub *ParseHub) Close(ctx context.Context) (*CloseReport, error) {
+	if !parsehub.watcher.close() {
+		return nil, ErrClosed
+	}
+
+	parsehub.logDebug("Close parsehub client", "operation", "ParseHub.Close")
+
+	var err error
+	select {
+	case <-parsehub.watcher.drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	report := &CloseReport{
+		Pending:     parsehub.watcher.pending(),
+		DeadLetters: parsehub.DeadLetters(),
+	}
+
+	if !report.Empty() {
+		parsehub.logWarn("Client closed with unhandled runs", "operation", "ParseHub.Close", "pending", len(report.Pending), "dead_letters", len(report.DeadLetters), "error", err)
+	}
+
+	return report, err
+}
@@ -0,0 +1,163 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCloseWaitsForRunningHandler(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "complete", DataReady: true})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := make(chan struct{})
+
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		close(started)
+		<-release
+		close(handled)
+		return nil
+	})
+	go run.WatchAndHandle()
+
+	<-started
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+
+	report, err := parsehub.Close(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	select {
+	case <-handled:
+	default:
+		t.Fatal("close returned before handler finished")
+	}
+
+	if !report.Empty() {
+		t.Fatalf("expected empty report, got %+v", report)
+	}
+
+	if _, err := parsehub.Close(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed on second close, got %v", err)
+	}
+}
+
+func TestCloseReportsUnfinishedRuns(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "running", ProjectToken: "project", Status: "running"})
+	fake.setRun(RunResponse{RunToken: "finished", ProjectToken: "project", Status: "complete", DataReady: true})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	running := NewRun(parsehub, "running")
+	if err := running.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	running.SetHandler(func(run *Run) error { return nil })
+	go running.WatchAndHandle()
+
+	finished := NewRun(parsehub, "finished")
+	finished.SetHandler(func(run *Run) error {
+		close(started)
+		<-release
+		return nil
+	})
+	go finished.WatchAndHandle()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report, err := parsehub.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	pending := map[string]PendingRun{}
+	for _, run := range report.Pending {
+		pending[run.RunToken] = run
+	}
+
+	if run, ok := pending["running"]; !ok || run.Handling || run.ProjectToken != "project" {
+		t.Fatalf("expected unfinished running run, got %+v", report.Pending)
+	}
+
+	if run, ok := pending["finished"]; !ok || !run.Handling {
+		t.Fatalf("expected finished run in handling, got %+v", report.Pending)
+	}
+
+	if _, err := running.Wait(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed from Wait, got %v", err)
+	}
+
+	project := NewProject(parsehub, "project")
+	if _, err := project.RunWithHandlers(context.Background(), ProjectRunParams{}, handlersOf(func(run *Run) error { return nil })); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed from RunWithHandlers, got %v", err)
+	}
+}
+
+func TestCloseWaitsForHandlersOutsideWatcher(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	block := func(run *Run) error {
+		started <- run.token
+		<-release
+		return nil
+	}
+
+	webhook := parsehub.WebhookHandler(WebhookOptions{Handlers: RunHandlers{Complete: block}})
+	go postWebhook(webhook, "application/x-www-form-urlencoded", "run_token=webhook&status=complete&data_ready=1")
+
+	NewRun(parsehub, "loaded").SetHandler(block)
+	go parsehub.LoadRunFromBytes([]byte("run_token=loaded&status=complete&data_ready=1"))
+
+	<-started
+	<-started
+
+	parsehub.putDeadLetter("ParseHub.Replay", NewRun(parsehub, "failed"), errors.New("storage unavailable"), 1, block)
+
+	// handlers did not return before close context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	report, err := parsehub.Close(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if len(report.Pending) != 2 || !report.Pending[0].Handling || !report.Pending[1].Handling {
+		t.Fatalf("expected 2 handling runs, got %+v", report.Pending)
+	}
+
+	close(release)
+
+	select {
+	case <-parsehub.watcher.drained:
+	case <-time.After(time.Second):
+		t.Fatal("finished handlers are not drained")
+	}
+
+	if _, err := parsehub.LoadRunFromBytes([]byte("run_token=late&status=complete")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after close, got %v", err)
+	}
+
+	if err := parsehub.Replay(context.Background(), "failed"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed of replay after close, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package parsehub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Retry policy of failed run handlers
+type HandlerRetryPolicy struct {
+	// Maximum number of handler calls including the first one. Values less than 2 disable retries.
+	MaxAttempts int
+
+	// Delay before the second call. Doubles with every next call.
+	MinBackoff time.Duration
+
+	// Upper limit for delay between calls
+	MaxBackoff time.Duration
+}
+
+// Run which handler failed all attempts
+type DeadLetter struct {
+	RunToken string
+	Run      *Run
+
+	// Error of last handler call
+	Err error
+
+	Attempts int
+	FailedAt time.Time
+
+	handleFunc HandleRunFunc
+}
+
+// Called when run is put into dead letter queue
+type DeadLetterFunc func(letter *DeadLetter)
+
+// Set retry policy of failed run handlers
+func WithHandlerRetryPolicy(policy HandlerRetryPolicy) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.handlerRetryPolicy = policy
+	}
+}
+
+// Set callback of runs put into dead letter queue
+func WithDeadLetterFunc(deadLetterFunc DeadLetterFunc) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.deadLetterFunc = deadLetterFunc
+	}
+}
+
+// Error of recovered handler panic
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("parsehub: handler panic: %v", e.Value)
+}
+
+// Queue of runs with failed handlers by run token
+type deadLetterQueue struct {
+	mu      sync.Mutex
+	letters []*DeadLetter
+}
+
+func (queue *deadLetterQueue) put(letter *DeadLetter) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	for i, existing := range queue.letters {
+		if existing.Run.token == letter.Run.token {
+			queue.letters[i] = letter
+			return
+		}
+	}
+
+	queue.letters = append(queue.letters, letter)
+}
+
+func (queue *deadLetterQueue) get(runToken string) *DeadLetter {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	for _, letter := range queue.letters {
+		if letter.Run.token == runToken {
+			return letter
+		}
+	}
+
+	return nil
+}
+
+func (queue *deadLetterQueue) remove(runToken string) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	for i, letter := range queue.letters {
+		if letter.Run.token == runToken {
+			queue.letters = append(queue.letters[:i], queue.letters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (queue *deadLetterQueue) list() []*DeadLetter {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	letters := make([]*DeadLetter, len(queue.letters))
+	copy(letters, queue.letters)
+
+	return letters
+}
+
+// Returns runs which handlers failed all attempts, oldest first
+func (parsehub *ParseHub) DeadLetters() []*DeadLetter {
+	return parsehub.deadLetters.list()
+}
+
+// Calls handler of dead letter run again with client handler retry policy.
+// Run is removed from dead letter queue when handler succeeds.
+// Returns ErrClosed after client is closed, Close waits for replayed handler.
+func (parsehub *ParseHub) Replay(ctx context.Context, runToken string) error {
+	letter := parsehub.deadLetters.get(runToken)
+	if letter == nil {
+		return fmt.Errorf("parsehub: run %s is not in dead letter queue", runToken)
+	}
+
+	if !parsehub.watcher.startCall(letter.Run) {
+		return ErrClosed
+	}
+	defer parsehub.watcher.finishCall(letter.Run)
+
+	parsehub.deadLetters.remove(runToken)
+
+	return parsehub.callHandler(ctx, "ParseHub.Replay", letter.Run, letter.handleFunc)
+}
+
+// Calls handler with retries and recovered panics unless run is already handled.
+// Run is removed from registry on success and put into dead letter queue on failure.
+func (parsehub *ParseHub) callHandler(ctx context.Context, operation string, run *Run, handleFunc HandleRunFunc) error {
+	policy := parsehub.handlerRetryPolicy
+
+	acquired, err := parsehub.acquireHandling(run, operation)
+	if err != nil {
+		parsehub.putDeadLetter(operation, run, err, 0, handleFunc)
+		return err
+	}
+
+	if !acquired {
+		return nil
+	}
+
+	run.setContext(ctx)
+	attempt := 1
+
+	for ; ; attempt++ {
+		if err = safeCall(handleFunc, run); err == nil {
+			parsehub.runRegistry.delete(run)
+			parsehub.deleteWatch(run)
+			parsehub.deadLetters.remove(run.token)
+			return nil
+		}
+
+		parsehub.logWarn("Handle run error", "operation", operation, "run_token", run.token, "attempt", attempt, "error", err)
+
+		if attempt >= policy.MaxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(jitteredBackoff(attempt, policy.MinBackoff, policy.MaxBackoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-parsehub.watcher.closing:
+			timer.Stop()
+		case <-timer.C:
+			continue
+		}
+
+		break
+	}
+
+	parsehub.releaseHandling(run, operation)
+	parsehub.putDeadLetter(operation, run, err, attempt, handleFunc)
+
+	return err
+}
+
+// Puts run into dead letter queue and notifies callback
+func (parsehub *ParseHub) putDeadLetter(operation string, run *Run, err error, attempts int, handleFunc HandleRunFunc) {
+	letter := &DeadLetter{
+		RunToken:   run.token,
+		Run:        run,
+		Err:        err,
+		Attempts:   attempts,
+		FailedAt:   time.Now(),
+		handleFunc: handleFunc,
+	}
+	parsehub.deadLetters.put(letter)
+
+	parsehub.logError("Run put into dead letter queue", "operation", operation, "run_token", run.token, "attempt", attempts, "error", err)
+	if parsehub.deadLetterFunc != nil {
+		parsehub.deadLetterFunc(letter)
+	}
+}
+
+// Calls handler and converts panic to *PanicError
+func safeCall(handleFunc HandleRunFunc, run *Run) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+
+	return handleFunc(run)
+}
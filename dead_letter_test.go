@@ -0,0 +1,99 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandlerRetriedUntilSuccess(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "complete", DataReady: true})
+
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithHandlerRetryPolicy(HandlerRetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}),
+	)
+
+	var calls int32
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("storage unavailable")
+		}
+		return nil
+	})
+	run.WatchAndHandle()
+
+	if calls != 3 {
+		t.Fatalf("expected 3 handler calls, got %d", calls)
+	}
+
+	if letters := parsehub.DeadLetters(); len(letters) != 0 {
+		t.Fatalf("expected empty dead letter queue, got %d letters", len(letters))
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatal("handled run is not removed from registry")
+	}
+}
+
+func TestHandlerPanicGoesToDeadLetterQueue(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "complete", DataReady: true})
+
+	var notified *DeadLetter
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithHandlerRetryPolicy(HandlerRetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}),
+		WithDeadLetterFunc(func(letter *DeadLetter) {
+			notified = letter
+		}),
+	)
+
+	var calls int32
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			panic("nil map")
+		}
+		return nil
+	})
+	run.WatchAndHandle()
+
+	letters := parsehub.DeadLetters()
+	if len(letters) != 1 || letters[0] != notified {
+		t.Fatalf("expected one dead letter passed to callback, got %v", letters)
+	}
+
+	var panicErr *PanicError
+	if !errors.As(notified.Err, &panicErr) || panicErr.Value != "nil map" {
+		t.Fatalf("expected handler panic error, got %v", notified.Err)
+	}
+
+	if notified.Attempts != 2 || notified.Run != run {
+		t.Fatalf("unexpected dead letter %+v", notified)
+	}
+
+	if parsehub.runRegistry.len() != 1 {
+		t.Fatal("dead letter run is removed from registry")
+	}
+
+	if err := parsehub.Replay(context.Background(), "run"); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	if len(parsehub.DeadLetters()) != 0 || parsehub.runRegistry.len() != 0 {
+		t.Fatal("replayed run is not removed from dead letter queue and registry")
+	}
+
+	if err := parsehub.Replay(context.Background(), "run"); err == nil {
+		t.Fatal("expected error on replay of unknown run")
+	}
+}
@@ -0,0 +1,130 @@
+package parsehub
+
+import (
+	"sync"
+	"time"
+)
+
+// Default time handled runs are remembered by in-memory dedup store
+const DefaultDedupTTL = 24 * time.Hour
+
+// Maximal interval of removing expired keys from in-memory dedup store
+const dedupSweepInterval = time.Minute
+
+// Storage of handled runs keyed by run token and terminal status.
+// Implementations must be safe for concurrent use.
+type DedupStore interface {
+	// Reserves key for handling. Returns false if key is already reserved.
+	Acquire(key string) (bool, error)
+
+	// Releases reservation of failed handling so it can be retried
+	Release(key string) error
+}
+
+// Set storage of handled runs. Defaults to in-memory store with DefaultDedupTTL.
+// Share store between clients to handle each run at most once across them.
+func WithDedupStore(store DedupStore) Option {
+	return func(parsehub *ParseHub) {
+		if store != nil {
+			parsehub.dedupStore = store
+		}
+	}
+}
+
+// In-memory DedupStore forgetting keys after TTL
+type MemoryDedupStore struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	keys map[string]time.Time
+
+	// Expired keys are removed not more often than once per sweep interval
+	sweepInterval time.Duration
+	nextSweep     time.Time
+}
+
+// Creates new in-memory dedup store. Non-positive TTL defaults to DefaultDedupTTL.
+func NewMemoryDedupStore(ttl time.Duration) *MemoryDedupStore {
+	if ttl <= 0 {
+		ttl = DefaultDedupTTL
+	}
+
+	sweepInterval := dedupSweepInterval
+	if ttl < sweepInterval {
+		sweepInterval = ttl
+	}
+
+	return &MemoryDedupStore{ttl: ttl, keys: map[string]time.Time{}, sweepInterval: sweepInterval}
+}
+
+func (store *MemoryDedupStore) Acquire(key string) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	if now.After(store.nextSweep) {
+		store.sweep(now)
+	}
+
+	if expires, ok := store.keys[key]; ok && !now.After(expires) {
+		return false, nil
+	}
+
+	store.keys[key] = now.Add(store.ttl)
+
+	return true, nil
+}
+
+// Removes expired keys and schedules next sweep
+func (store *MemoryDedupStore) sweep(now time.Time) {
+	for key, expires := range store.keys {
+		if now.After(expires) {
+			delete(store.keys, key)
+		}
+	}
+
+	store.nextSweep = now.Add(store.sweepInterval)
+}
+
+func (store *MemoryDedupStore) Release(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.keys, key)
+
+	return nil
+}
+
+// Returns dedup key of finished run
+func dedupKey(run *Run) string {
+	status := ""
+	if snapshot := run.GetResponse(); snapshot != nil {
+		status = snapshot.Status.String()
+	}
+
+	return run.token + ":" + status
+}
+
+// Reserves handling of finished run. Returns false if run is already handled.
+func (parsehub *ParseHub) acquireHandling(run *Run, operation string) (bool, error) {
+	key := dedupKey(run)
+
+	acquired, err := parsehub.dedupStore.Acquire(key)
+	if err != nil {
+		parsehub.logError("Dedup store error", "operation", operation, "run_token", run.token, "error", err)
+		return false, err
+	}
+
+	if !acquired {
+		parsehub.logDebug("Run is already handled, skip", "operation", operation, "run_token", run.token, "key", key)
+	}
+
+	return acquired, nil
+}
+
+// Releases handling of run after handler failure
+func (parsehub *ParseHub) releaseHandling(run *Run, operation string) {
+	if err := parsehub.dedupStore.Release(dedupKey(run)); err != nil {
+		parsehub.logError("Dedup store error", "operation", operation, "run_token", run.token, "error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package parsehub
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	store := NewMemoryDedupStore(20 * time.Millisecond)
+
+	if acquired, _ := store.Acquire("run:complete"); !acquired {
+		t.Fatal("new key is not acquired")
+	}
+
+	if acquired, _ := store.Acquire("run:complete"); acquired {
+		t.Fatal("key is acquired twice")
+	}
+
+	if acquired, _ := store.Acquire("run:error"); !acquired {
+		t.Fatal("key of other status is not acquired")
+	}
+
+	store.Release("run:error")
+	if acquired, _ := store.Acquire("run:error"); !acquired {
+		t.Fatal("released key is not acquired")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if acquired, _ := store.Acquire("run:complete"); !acquired {
+		t.Fatal("expired key is not acquired")
+	}
+}
+
+func TestMemoryDedupStoreSweepsExpiredKeys(t *testing.T) {
+	store := NewMemoryDedupStore(time.Hour)
+
+	store.Acquire("fresh:complete")
+	store.Acquire("expired:complete")
+
+	// sweep is not due yet, expired key is kept but can be acquired again
+	store.keys["expired:complete"] = time.Now().Add(-time.Second)
+	store.Acquire("other:complete")
+	if len(store.keys) != 3 {
+		t.Fatalf("keys are swept before sweep interval, got %d keys", len(store.keys))
+	}
+
+	if acquired, _ := store.Acquire("expired:complete"); !acquired {
+		t.Fatal("expired key is not acquired")
+	}
+
+	store.keys["expired:complete"] = time.Now().Add(-time.Second)
+	store.nextSweep = time.Now().Add(-time.Second)
+	store.Acquire("other:complete")
+
+	if _, ok := store.keys["expired:complete"]; ok || len(store.keys) != 2 {
+		t.Fatalf("expired key is not swept, got %v", store.keys)
+	}
+}
+
+func TestWebhookDuplicatesHandledOnce(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	var calls int32
+	failing := int32(1)
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Handlers: RunHandlers{Complete: func(run *Run) error {
+			if atomic.CompareAndSwapInt32(&failing, 1, 0) {
+				return errors.New("storage unavailable")
+			}
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}},
+	})
+
+	body := "run_token=run&status=complete&data_ready=1"
+
+	// failed delivery is retried by ParseHub
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", body); recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if recorder := postWebhook(handler, "application/x-www-form-urlencoded", body); recorder.Code != http.StatusOK {
+				t.Errorf("expected 200, got %d", recorder.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected handler called once, got %d", calls)
+	}
+}
+
+func TestWebhookAndWatcherHandleRunOnce(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "complete", DataReady: true})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	var calls int32
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	handler := parsehub.WebhookHandler(WebhookOptions{})
+	if recorder := postWebhook(handler, "application/json", `{"run_token":"run","status":"complete","data_ready":1}`); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	run.WatchAndHandle()
+
+	if calls != 1 {
+		t.Fatalf("expected handler called once, got %d", calls)
+	}
+}
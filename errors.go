@@ -0,0 +1,191 @@
+package parsehub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Sentinel errors of ParseHub API responses.
+// Use errors.Is to check error returned by adapter methods.
+var (
+	ErrBadRequest   = errors.New("parsehub: bad request")
+	ErrUnauthorized = errors.New("parsehub: unauthorized, check api key")
+	ErrForbidden    = errors.New("parsehub: forbidden, check api key")
+	ErrNotFound     = errors.New("parsehub: not found")
+	ErrRateLimited  = errors.New("parsehub: rate limited")
+	ErrServer       = errors.New("parsehub: server error")
+	ErrUnexpected   = errors.New("parsehub: unexpected response status")
+)
+
+// Returned by methods that got invalid params before any request was sent
+var ErrInvalidParams = errors.New("parsehub: invalid params")
+
+// Terminal states of unsuccessful runs
+var (
+	ErrRunFailed    = errors.New("parsehub: run failed")
+	ErrRunCancelled = errors.New("parsehub: run cancelled")
+	ErrGuardTripped = errors.New("parsehub: run guard tripped")
+)
+
+// Returned by methods watching runs after client is closed
+var ErrClosed = errors.New("parsehub: client is closed")
+
+// Returned by LoadRunFromRequest for request body over DefaultWebhookMaxBodyBytes
+var ErrBodyTooLarge = errors.New("parsehub: request body too large")
+
+// Error response of ParseHub API.
+// Unwraps to one of sentinel errors according to status code.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s %s returned status %d", e.Unwrap(), e.Method, e.Endpoint, e.StatusCode)
+}
+
+// Returns sentinel error matching status code
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+
+	return ErrUnexpected
+}
+
+// JSON decode error of ParseHub response
+type DecodeError struct {
+	Endpoint string
+	Body     string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("parsehub: decode response of %s: %s", e.Endpoint, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Validation error of params field.
+// Matches ErrInvalidParams with errors.Is.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrInvalidParams, e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidParams
+}
+
+// Error of run finished without success.
+// Unwraps to ErrRunCancelled or ErrRunFailed.
+type RunError struct {
+	RunToken string
+	Status   RunStatus
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%s: run %s finished with status %s", e.Unwrap(), e.RunToken, e.Status)
+}
+
+func (e *RunError) Unwrap() error {
+	if e.Status == RunStatusCancelled {
+		return ErrRunCancelled
+	}
+
+	return ErrRunFailed
+}
+
+// Error of run cancelled by guard.
+// Matches ErrGuardTripped and ErrRunCancelled with errors.Is.
+type GuardError struct {
+	RunToken string
+
+	// One of Guard* constants
+	Guard string
+
+	// Traversed pages and run duration when guard was tripped
+	Pages   int64
+	Elapsed time.Duration
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("%s: run %s exceeded %s with %d pages after %s", ErrGuardTripped, e.RunToken, e.Guard, e.Pages, e.Elapsed)
+}
+
+func (e *GuardError) Is(target error) bool {
+	return target == ErrGuardTripped || target == ErrRunCancelled
+}
+
+// Checks response status code and returns *APIError for non 2xx and 3xx codes
+func checkStatusCode(method string, endpoint string, statusCode int, body []byte) error {
+	if statusCode < 400 {
+		return nil
+	}
+
+	return &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		Endpoint:   endpoint,
+		Body:       string(body),
+	}
+}
+
+// Error with secrets masked in message
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Masks api key and client secrets in error message and known error fields
+func (parsehub *ParseHub) redactError(err error) error {
+	switch e := err.(type) {
+	case *url.Error:
+		e.URL = parsehub.redactor.Redact(e.URL)
+	case *APIError:
+		e.Body = parsehub.redactor.Redact(e.Body)
+	case *DecodeError:
+		e.Body = parsehub.redactor.Redact(e.Body)
+	}
+
+	message := err.Error()
+	if redacted := parsehub.redactor.Redact(message); redacted != message {
+		return &redactedError{message: redacted, err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package parsehub
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIErrorSentinels(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusInternalServerError, ErrServer},
+		{http.StatusBadGateway, ErrServer},
+		{http.StatusServiceUnavailable, ErrServer},
+		{http.StatusConflict, ErrUnexpected},
+		{http.StatusTeapot, ErrUnexpected},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.statusCode), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "response body", test.statusCode)
+			}))
+			defer server.Close()
+
+			parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(RetryPolicy{}))
+
+			_, err := parsehub.GetRun("run")
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, err)
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *APIError, got %T", err)
+			}
+
+			if apiErr.StatusCode != test.statusCode || apiErr.Method != http.MethodGet || apiErr.Endpoint != "v2/runs/run" || apiErr.Body != "response body\n" {
+				t.Fatalf("unexpected api error %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestCheckStatusCodeAcceptsSuccess(t *testing.T) {
+	for _, statusCode := range []int{http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusFound} {
+		if err := checkStatusCode(http.MethodGet, "v2/projects", statusCode, nil); err != nil {
+			t.Fatalf("unexpected error for status %d: %v", statusCode, err)
+		}
+	}
+}
+
+func TestDecodeErrorWrapsCause(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>maintenance</html>"))
+	}))
+	defer server.Close()
+
+	parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL))
+
+	_, err := parsehub.GetRun("run")
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected *DecodeError, got %T: %v", err, err)
+	}
+
+	if decodeErr.Endpoint != "v2/runs/run" || decodeErr.Body != "<html>maintenance</html>" {
+		t.Fatalf("unexpected decode error %+v", decodeErr)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("decode error does not wrap json error, got %v", decodeErr.Err)
+	}
+}
+
+func TestRunAndGuardErrors(t *testing.T) {
+	if err := error(&RunError{RunToken: "run", Status: RunStatusCancelled}); !errors.Is(err, ErrRunCancelled) || errors.Is(err, ErrRunFailed) {
+		t.Fatalf("unexpected cancelled run error %v", err)
+	}
+
+	if err := error(&RunError{RunToken: "run", Status: RunStatusError}); !errors.Is(err, ErrRunFailed) {
+		t.Fatalf("unexpected failed run error %v", err)
+	}
+
+	if err := error(&GuardError{RunToken: "run", Guard: GuardMaxPages}); !errors.Is(err, ErrGuardTripped) || !errors.Is(err, ErrRunCancelled) {
+		t.Fatalf("unexpected guard error %v", err)
+	}
+}
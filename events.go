@@ -0,0 +1,195 @@
+package parsehub
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RunEventType int
+
+const (
+	// Status of run changed to initialized, queued or running
+	RunEventStatus RunEventType = iota + 1
+
+	// Number of traversed pages changed
+	RunEventPages
+
+	// Run finished with status complete, error or cancelled. Last event of stream.
+	RunEventFinished
+)
+
+// Change of watched run
+type RunEvent struct {
+	Type     RunEventType
+	RunToken string
+
+	// Status before and after change. PreviousStatus is empty for first known status.
+	PreviousStatus RunStatus
+	Status         RunStatus
+
+	// Number of traversed pages before and after change
+	PreviousPages int64
+	Pages         int64
+
+	// Run data after change with EndTime and DataReady of finished run
+	Snapshot *RunSnapshot
+}
+
+// Streams lifecycle events of run until it is finished or context is done.
+// First event reports current status if run data is loaded.
+// Run is polled by client scheduler while stream is open.
+// Channel is closed after RunEventFinished event, when context is done, client is closed
+// or watching is stopped by permanent error of ParseHub API.
+func (r *Run) Events(ctx context.Context) <-chan RunEvent {
+	subscription := &runSubscription{
+		ctx:    ctx,
+		events: make(chan RunEvent),
+		signal: make(chan struct{}, 1),
+	}
+
+	r.mu.Lock()
+	subscription.push(runEvents(r.token, nil, r.snapshot))
+	if r.snapshot == nil || !r.snapshot.Status.IsTerminal() {
+		r.subscriptions = append(r.subscriptions, subscription)
+	}
+	r.mu.Unlock()
+
+	go subscription.pump(r)
+
+	return subscription.events
+}
+
+// Publishes events of run data change to subscriptions. Must be called with run lock held.
+func (r *Run) publish(previous *RunSnapshot, next *RunSnapshot) {
+	events := runEvents(r.token, previous, next)
+	if len(events) == 0 {
+		return
+	}
+
+	active := r.subscriptions[:0]
+	for _, subscription := range r.subscriptions {
+		if subscription.push(events) {
+			active = append(active, subscription)
+		}
+	}
+	r.subscriptions = active
+}
+
+// Returns events of change from previous to next run data
+func runEvents(runToken string, previous *RunSnapshot, next *RunSnapshot) []RunEvent {
+	if next == nil {
+		return nil
+	}
+
+	event := RunEvent{
+		RunToken: runToken,
+		Status:   next.Status,
+		Pages:    next.Pages,
+		Snapshot: next.clone(),
+	}
+
+	if previous != nil {
+		event.PreviousStatus = previous.Status
+		event.PreviousPages = previous.Pages
+	}
+
+	events := []RunEvent{}
+
+	if previous != nil && previous.Pages != next.Pages {
+		pagesEvent := event
+		pagesEvent.Type = RunEventPages
+		events = append(events, pagesEvent)
+	}
+
+	if previous == nil || previous.Status != next.Status {
+		statusEvent := event
+		statusEvent.Type = RunEventStatus
+		if next.Status.IsTerminal() {
+			statusEvent.Type = RunEventFinished
+		}
+		events = append(events, statusEvent)
+	}
+
+	return events
+}
+
+// Subscription to run events.
+// Events are queued without blocking run updates and delivered by pump.
+type runSubscription struct {
+	ctx    context.Context
+	events chan RunEvent
+	signal chan struct{}
+
+	mu       sync.Mutex
+	queue    []RunEvent
+	finished bool
+}
+
+// Queues events. Returns false if subscription is finished or its context is done.
+func (s *runSubscription) push(events []RunEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished || s.ctx.Err() != nil {
+		return false
+	}
+
+	for _, event := range events {
+		s.queue = append(s.queue, event)
+		if event.Type == RunEventFinished {
+			s.finished = true
+		}
+	}
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+
+	return !s.finished
+}
+
+// Delivers queued events to channel and keeps run watched until finished event
+func (s *runSubscription) pump(run *Run) {
+	defer close(s.events)
+
+	for {
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		finished := s.finished
+		s.mu.Unlock()
+
+		for _, event := range queue {
+			select {
+			case s.events <- event:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		if finished {
+			return
+		}
+
+		if run.parsehub.watcher.isClosed() {
+			return
+		}
+
+		// watch again if previous watch was stopped by its context
+		run.parsehub.watcher.watch(s.ctx, run)
+
+		select {
+		case <-s.signal:
+		case <-s.ctx.Done():
+			return
+		case <-run.parsehub.watcher.closing:
+		case <-time.After(run.parsehub.pollInterval):
+		}
+
+		if run.watchError() != nil {
+			return
+		}
+	}
+}
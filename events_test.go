@@ -0,0 +1,116 @@
+package parsehub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunEventsStreamsTransitions(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "initialized"})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	run, err := parsehub.GetRun("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := run.Events(ctx)
+
+	updates := []RunResponse{
+		{RunToken: "run", Status: "queued"},
+		{RunToken: "run", Status: "running", Pages: 1},
+		{RunToken: "run", Status: "running", Pages: 5},
+		{RunToken: "run", Status: "complete", Pages: 7, DataReady: true, EndTime: &testEndTime},
+	}
+
+	go func() {
+		for _, update := range updates {
+			time.Sleep(30 * time.Millisecond)
+			fake.setRun(update)
+		}
+	}()
+
+	received := []RunEvent{}
+	for event := range events {
+		received = append(received, event)
+	}
+
+	expected := []struct {
+		eventType RunEventType
+		status    RunStatus
+		pages     int64
+	}{
+		{RunEventStatus, "initialized", 0},
+		{RunEventStatus, "queued", 0},
+		{RunEventPages, "running", 1},
+		{RunEventStatus, "running", 1},
+		{RunEventPages, "running", 5},
+		{RunEventPages, "complete", 7},
+		{RunEventFinished, "complete", 7},
+	}
+
+	if len(received) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(received), received)
+	}
+
+	for i, event := range received {
+		if event.Type != expected[i].eventType || event.Status != expected[i].status || event.Pages != expected[i].pages {
+			t.Errorf("event %d: expected %+v, got type %d status %s pages %d", i, expected[i], event.Type, event.Status, event.Pages)
+		}
+	}
+
+	last := received[len(received)-1].Snapshot
+	if !last.DataReady || last.EndTime == nil {
+		t.Fatalf("finished event without end time and data ready: %+v", last)
+	}
+}
+
+func TestRunEventsOfFinishedRun(t *testing.T) {
+	run := NewRun(NewParseHub("test-api-key"), "run")
+	run.setResponse(&RunResponse{RunToken: "run", Status: "error", EndTime: &testEndTime})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events := []RunEvent{}
+	for event := range run.Events(ctx) {
+		events = append(events, event)
+	}
+
+	if len(events) != 1 || events[0].Type != RunEventFinished {
+		t.Fatalf("expected single finished event, got %+v", events)
+	}
+}
+
+func TestRunEventsClosedWithContext(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "running"})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := NewRun(parsehub, "run").Events(ctx)
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel is not closed")
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package parsehub
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"time"
 )
 
 // Set parsehub library logger
@@ -14,6 +18,118 @@ func ExampleSetLogger() {
 	SetLogger(LogLevelDebug, logger)
 }
 
+// Set logger of single client
+func ExampleWithLogger() {
+	logger := NewStdLogger(LogLevelWarning, log.New(os.Stderr, "parsehub ", log.LstdFlags))
+
+	parsehub := NewParseHub("__API_KEY__", WithLogger(logger))
+
+	if run, err := parsehub.GetRun("__RUN_TOKEN__"); err != nil {
+		// handle error
+	} else {
+		fmt.Printf("%+v", run)
+	}
+}
+
+// Create parsehub client with custom http client and headers
+func ExampleNewParseHub() {
+	parsehub := NewParseHub(
+		"__API_KEY__",
+		WithHTTPClient(&http.Client{Timeout: 30 * time.Second}),
+		WithBaseUrl("https://www.parsehub.com/api/"),
+		WithUserAgent("my-service/1.0"),
+		WithHeader("X-Request-Source", "my-service"),
+	)
+
+	if projects, err := parsehub.GetAllProjects(); err != nil {
+		// handle error
+	} else {
+		fmt.Println(len(projects))
+	}
+}
+
+// Configure retries of failed requests
+func ExampleWithRetryPolicy() {
+	policy := DefaultRetryPolicy
+	policy.MaxAttempts = 5
+	policy.MaxBackoff = time.Minute
+
+	parsehub := NewParseHub("__API_KEY__", WithRetryPolicy(policy))
+
+	if run, err := parsehub.GetRun("__RUN_TOKEN__"); err != nil {
+		// handle error
+	} else {
+		fmt.Printf("%+v", run)
+	}
+}
+
+// Retry failed handler and replay it from dead letter queue
+func ExampleWithHandlerRetryPolicy() {
+	parsehub := NewParseHub("__API_KEY__",
+		WithHandlerRetryPolicy(HandlerRetryPolicy{
+			MaxAttempts: 3,
+			MinBackoff:  time.Second,
+			MaxBackoff:  time.Minute,
+		}),
+		WithDeadLetterFunc(func(letter *DeadLetter) {
+			log.Printf("run %s handler failed %d times: %v", letter.RunToken, letter.Attempts, letter.Err)
+		}),
+	)
+
+	// later, after failure cause is fixed
+	for _, letter := range parsehub.DeadLetters() {
+		if err := parsehub.Replay(context.Background(), letter.RunToken); err != nil {
+			// handle error
+		}
+	}
+}
+
+// Share api key budget between goroutines
+func ExampleWithRateLimit() {
+	parsehub := NewParseHub(
+		"__API_KEY__",
+		WithRateLimit(RateLimit{RequestsPerSecond: 5, Burst: 10}),
+		WithMutatingRateLimit(RateLimit{RequestsPerSecond: 1, Burst: 2}),
+		WithRateLimitWaitFunc(func(operation string, waited time.Duration) {
+			log.Printf("%s waited %s in rate limiter", operation, waited)
+		}),
+	)
+
+	if run, err := parsehub.GetRun("__RUN_TOKEN__"); err != nil {
+		// handle error
+	} else {
+		fmt.Printf("%+v", run)
+	}
+}
+
+// Tag requests and log responses with middleware
+func ExampleWithMiddleware() {
+	tagging := func(next RoundTripFunc) RoundTripFunc {
+		return func(op Operation, request *http.Request) (*http.Response, error) {
+			request.Header.Set("X-Operation", op.Name)
+			return next(op, request)
+		}
+	}
+
+	logging := func(next RoundTripFunc) RoundTripFunc {
+		return func(op Operation, request *http.Request) (*http.Response, error) {
+			resp, err := next(op, request)
+			if err == nil {
+				log.Printf("%s run=%s attempt=%d status=%d", op.Name, op.RunToken, op.Attempt, resp.StatusCode)
+			}
+			return resp, err
+		}
+	}
+
+	parsehub := NewParseHub("__API_KEY__", WithMiddleware(tagging, logging))
+
+	if run, err := parsehub.GetRun("__RUN_TOKEN__"); err != nil {
+		// handle error
+	} else {
+		fmt.Printf("%+v", run)
+	}
+}
+
 // Run parsehub project with params and handle data async
 // with polling status
 func ExampleProject_Run() {
@@ -48,7 +164,158 @@ func ExampleProject_Run() {
 	// your code
 }
 
+// Run project and wait for result in straight-line code
+func ExampleRun_Wait() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	project := NewProject(parsehub, "__PROJECT_TOKEN__")
+
+	run, err := project.RunContext(ctx, ProjectRunParams{}, nil)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	if _, err := run.Wait(ctx); err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	val := map[string]interface{}{}
+	if err := run.LoadDataContext(ctx, &val); err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	fmt.Println("result", val)
+}
+
+// Show live progress of run
+func ExampleRun_Events() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	run, err := parsehub.GetRun("__RUN_TOKEN__")
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	for event := range run.Events(context.Background()) {
+		switch event.Type {
+		case RunEventStatus:
+			fmt.Printf("status %s -> %s\n", event.PreviousStatus, event.Status)
+		case RunEventPages:
+			fmt.Printf("pages %d\n", event.Pages)
+		case RunEventFinished:
+			fmt.Printf("finished %s at %s, data ready: %t\n", event.Status, event.Snapshot.EndTime, event.Snapshot.DataReady)
+		}
+	}
+}
+
+// Run project and handle every outcome separately
+func ExampleProject_RunWithHandlers() {
+	parsehub := NewParseHub("__API_KEY__")
+	project := NewProject(parsehub, "__PROJECT_TOKEN__")
+
+	project.RunWithHandlers(context.Background(), ProjectRunParams{}, RunHandlers{
+		Complete: func(run *Run) error {
+			val := map[string]interface{}{}
+			if err := run.LoadData(&val); err != nil {
+				return err
+			}
+
+			fmt.Println("result", val)
+			return nil
+		},
+		Error: func(run *Run) error {
+			log.Printf("run %s failed, partial data ready", run.GetResponse().RunToken)
+			return nil
+		},
+		Cancelled: func(run *Run) error {
+			log.Printf("run %s cancelled", run.GetResponse().RunToken)
+			return nil
+		},
+		NoData: func(run *Run) error {
+			log.Printf("run %s finished without data", run.GetResponse().RunToken)
+			return nil
+		},
+	})
+
+	// your code
+}
+
 // Load data from string
+func ExampleProject_RunWithNamedHandler() {
+	// runs started by previous process are watched again on start
+	parsehub := NewParseHub("__API_KEY__",
+		WithWatchStore(NewFileWatchStore("/var/lib/app/parsehub-watches.json")),
+		WithNamedHandlers("export", RunHandlers{
+			Complete: func(run *Run) error {
+				fmt.Printf("export data of tenant %s", run.Metadata()["tenant"])
+				return nil
+			},
+		}),
+	)
+
+	project := NewProject(parsehub, "__PROJECT_TOKEN__")
+
+	if _, err := project.RunWithNamedHandler(context.Background(), ProjectRunParams{}, "export", map[string]string{"tenant": "acme"}); err != nil {
+		// handle error
+	}
+}
+
+func ExampleParseHub_Close() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	// on shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report, err := parsehub.Close(ctx)
+	if err != nil {
+		// some handlers did not finish in time
+	}
+
+	for _, run := range report.Pending {
+		fmt.Printf("run %s of project %s is not handled\n", run.RunToken, run.ProjectToken)
+	}
+}
+
+func ExampleParseHub_WebhookHandler() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	http.Handle("/parsehub/webhook", parsehub.WebhookHandler(WebhookOptions{
+		Projects: map[string]RunHandlers{
+			"__PROJECT_TOKEN__": {
+				Complete: func(run *Run) error {
+					data := map[string]interface{}{}
+					return run.LoadData(&data)
+				},
+			},
+		},
+	}))
+
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
+
+func ExampleParseHub_LoadRunFromRequest() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	http.HandleFunc("/parsehub/webhook", func(w http.ResponseWriter, r *http.Request) {
+		run, err := parsehub.LoadRunFromRequest(r)
+		if run != nil && err != nil {
+			// handler failed, ParseHub retries webhook
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Println("run status", run.GetResponse().Status)
+	})
+}
+
 func ExampleParseHub_LoadRunFromBytes() {
 	runJsonBytes := []byte("__RUN_FROM_WEBHOOK__")
 
@@ -86,7 +353,61 @@ func ExampleRun_LoadData() {
 	}
 }
 
+// Get parsehub project with deadline
+func ExampleParseHub_GetProjectContext() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if project, err := parsehub.GetProjectContext(ctx, "__PROJECT_TOKEN__"); err != nil {
+		// handle error
+	} else {
+		fmt.Printf("%+v", project)
+	}
+}
+
+// Check type of ParseHub error
+func ExampleAPIError() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	_, err := parsehub.GetRun("__RUN_TOKEN__")
+
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// run was deleted
+	case errors.Is(err, ErrUnauthorized):
+		// check api key
+	case errors.As(err, &apiErr):
+		log.Printf("status %d from %s %s", apiErr.StatusCode, apiErr.Method, apiErr.Endpoint)
+	}
+}
+
 // Get parsehub project
+func ExampleProject_Templates() {
+	parsehub := NewParseHub("__API_KEY__")
+
+	project, err := parsehub.GetProject("__PROJECT_TOKEN__")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	options, err := project.Options()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	templates, err := project.Templates()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, template := range templates {
+		fmt.Printf("template %s: %d commands, start template %t\n", template.Name, len(template.Commands), template.Name == options.StartTemplate)
+	}
+}
+
 func ExampleParseHub_GetProject() {
 	parsehub := NewParseHub("__API_KEY__")
 
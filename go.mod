@@ -1,3 +1,3 @@
 module github.com/defval/parsehub
 
-go 1.12
+go 1.13
@@ -0,0 +1,49 @@
+package parsehub
+
+import (
+	"errors"
+	"time"
+)
+
+// Limits of watched run. When a limit is exceeded run is cancelled.
+// Zero values disable guards.
+type RunGuards struct {
+	// Maximum duration of run since its start time.
+	// Watching start time is used if ParseHub did not report start time yet.
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
+
+	// Maximum number of traversed pages
+	MaxPages int64 `json:"max_pages,omitempty"`
+}
+
+// Names of guards
+const (
+	GuardMaxDuration = "MaxDuration"
+	GuardMaxPages    = "MaxPages"
+)
+
+// Validates guards and returns *ValidationError with name of bad field
+func (guards RunGuards) Validate() error {
+	if guards.MaxDuration < 0 {
+		return &ValidationError{Field: "Guards.MaxDuration", Err: errors.New("must not be negative")}
+	}
+
+	if guards.MaxPages < 0 {
+		return &ValidationError{Field: "Guards.MaxPages", Err: errors.New("must not be negative")}
+	}
+
+	return nil
+}
+
+// Checks run data and run duration against guards. Returns nil if no guard is tripped.
+func (guards RunGuards) check(snapshot *RunSnapshot, elapsed time.Duration) *GuardError {
+	if guards.MaxPages > 0 && snapshot.Pages > guards.MaxPages {
+		return &GuardError{RunToken: snapshot.RunToken, Guard: GuardMaxPages, Pages: snapshot.Pages, Elapsed: elapsed}
+	}
+
+	if guards.MaxDuration > 0 && elapsed > guards.MaxDuration {
+		return &GuardError{RunToken: snapshot.RunToken, Guard: GuardMaxDuration, Pages: snapshot.Pages, Elapsed: elapsed}
+	}
+
+	return nil
+}
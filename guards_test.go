@@ -0,0 +1,142 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGuardCancelsRunawayRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		guards RunGuards
+		guard  string
+	}{
+		{"pages", RunGuards{MaxPages: 10}, GuardMaxPages},
+		{"duration", RunGuards{MaxDuration: 30 * time.Millisecond}, GuardMaxDuration},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeParseHub()
+			defer fake.Close()
+
+			parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+			project := NewProject(parsehub, "project")
+			run, err := project.Run(ProjectRunParams{Guards: test.guards}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fake.setRun(RunResponse{RunToken: run.token, Status: "running", Pages: 5})
+			if test.guard == GuardMaxPages {
+				fake.setRun(RunResponse{RunToken: run.token, Status: "running", Pages: 50})
+			}
+
+			tripped := make(chan *GuardError, 1)
+			run.SetHandlers(RunHandlers{
+				GuardTripped: func(run *Run, guardErr *GuardError) error {
+					data := map[string]interface{}{}
+					if err := run.LoadData(&data); err != nil {
+						return err
+					}
+
+					tripped <- guardErr
+					return nil
+				},
+			})
+			run.WatchAndHandle()
+
+			select {
+			case guardErr := <-tripped:
+				if guardErr.Guard != test.guard {
+					t.Fatalf("expected %s guard, got %s", test.guard, guardErr.Guard)
+				}
+
+				if !errors.Is(guardErr, ErrGuardTripped) || !errors.Is(guardErr, ErrRunCancelled) {
+					t.Fatalf("guard error does not match sentinels: %v", guardErr)
+				}
+			default:
+				t.Fatal("guard tripped handler is not called")
+			}
+
+			if fake.requestCount(http.MethodPost, "/v2/runs/"+run.token+"/cancel") != 1 {
+				t.Fatal("run is not cancelled")
+			}
+
+			if status := run.GetResponse().Status; status != "cancelled" {
+				t.Fatalf("expected cancelled run, got %s", status)
+			}
+		})
+	}
+}
+
+func TestGuardsValidation(t *testing.T) {
+	params := ProjectRunParams{Guards: RunGuards{MaxPages: -1}}
+
+	var validationErr *ValidationError
+	if err := params.Validate(); !errors.As(err, &validationErr) || validationErr.Field != "Guards.MaxPages" {
+		t.Fatalf("expected validation error of Guards.MaxPages, got %v", err)
+	}
+}
+
+func TestGuardDurationSinceRunStart(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	// run reported by ParseHub as started long ago trips guard on first poll
+	fake.setRun(RunResponse{RunToken: "old", Status: "running", StartTime: time.Now().Add(-time.Hour)})
+
+	tripped := make(chan *GuardError, 2)
+	handlers := RunHandlers{
+		GuardTripped: func(run *Run, guardErr *GuardError) error {
+			tripped <- guardErr
+			return nil
+		},
+	}
+
+	run := NewRun(parsehub, "old")
+	run.SetHandlers(handlers)
+	run.SetGuards(RunGuards{MaxDuration: 30 * time.Minute})
+	run.WatchAndHandle()
+
+	select {
+	case guardErr := <-tripped:
+		if guardErr.Elapsed < time.Hour {
+			t.Fatalf("expected elapsed since start time, got %s", guardErr.Elapsed)
+		}
+	default:
+		t.Fatal("guard of run started long ago is not tripped")
+	}
+
+	// duration of run without start time is kept over watches
+	fake.setRun(RunResponse{RunToken: "new", Status: "running"})
+
+	run = NewRun(parsehub, "new")
+	run.SetHandlers(handlers)
+	run.SetGuards(RunGuards{MaxDuration: 60 * time.Millisecond})
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		run.WatchAndHandleContext(ctx)
+		cancel()
+
+		if len(tripped) != 0 {
+			break
+		}
+	}
+
+	select {
+	case guardErr := <-tripped:
+		if guardErr.Guard != GuardMaxDuration {
+			t.Fatalf("unexpected guard %s", guardErr.Guard)
+		}
+	default:
+		t.Fatal("duration is reset by watching again")
+	}
+}
@@ -0,0 +1,59 @@
+package parsehub
+
+// Handlers of finished run by outcome. Nil handlers are skipped.
+type RunHandlers struct {
+	// Run finished with status complete
+	Complete HandleRunFunc
+
+	// Run finished with status error
+	Error HandleRunFunc
+
+	// Run finished with status cancelled
+	Cancelled HandleRunFunc
+
+	// Run finished without ready data. Called instead of status handler if set.
+	NoData HandleRunFunc
+
+	// Run was cancelled by tripped guard. Data extracted so far is available.
+	// Called instead of Cancelled and NoData handlers if set.
+	GuardTripped HandleGuardFunc
+}
+
+// Handler of run cancelled by guard
+type HandleGuardFunc func(run *Run, guardErr *GuardError) error
+
+// Handlers calling handleFunc for every finished run
+func handlersOf(handleFunc HandleRunFunc) RunHandlers {
+	return RunHandlers{
+		Complete:  handleFunc,
+		Error:     handleFunc,
+		Cancelled: handleFunc,
+	}
+}
+
+// Checks whether no handler is set
+func (handlers RunHandlers) empty() bool {
+	return handlers.Complete == nil &&
+		handlers.Error == nil &&
+		handlers.Cancelled == nil &&
+		handlers.NoData == nil &&
+		handlers.GuardTripped == nil
+}
+
+// Returns handler for finished run data or nil
+func (handlers RunHandlers) handler(snapshot *RunSnapshot) HandleRunFunc {
+	if !snapshot.DataReady && handlers.NoData != nil {
+		return handlers.NoData
+	}
+
+	switch snapshot.Status {
+	case RunStatusComplete:
+		return handlers.Complete
+	case RunStatusError:
+		return handlers.Error
+	case RunStatusCancelled:
+		return handlers.Cancelled
+	}
+
+	return nil
+}
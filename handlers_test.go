@@ -0,0 +1,121 @@
+package parsehub
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunHandlersByOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		response RunResponse
+		expected string
+	}{
+		{"complete", RunResponse{Status: "complete", DataReady: true}, "complete"},
+		{"error with data", RunResponse{Status: "error", DataReady: true}, "error"},
+		{"cancelled with data", RunResponse{Status: "cancelled", DataReady: true}, "cancelled"},
+		{"error without data", RunResponse{Status: "error"}, "no data"},
+		{"cancelled without data", RunResponse{Status: "cancelled"}, "no data"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeParseHub()
+			defer fake.Close()
+
+			test.response.RunToken = "run"
+			fake.setRun(test.response)
+
+			parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+			handled := make(chan string, 1)
+			handleAs := func(outcome string) HandleRunFunc {
+				return func(run *Run) error {
+					handled <- outcome
+					return nil
+				}
+			}
+
+			run := NewRun(parsehub, "run")
+			run.SetHandlers(RunHandlers{
+				Complete:  handleAs("complete"),
+				Error:     handleAs("error"),
+				Cancelled: handleAs("cancelled"),
+				NoData:    handleAs("no data"),
+			})
+			run.WatchAndHandle()
+
+			select {
+			case outcome := <-handled:
+				if outcome != test.expected {
+					t.Fatalf("expected %s handler, got %s", test.expected, outcome)
+				}
+			default:
+				t.Fatal("handler is not called")
+			}
+		})
+	}
+}
+
+func TestWatcherSurvivesPollErrors(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "complete", DataReady: true})
+
+	var polls int32
+	failing := func(next RoundTripFunc) RoundTripFunc {
+		return func(op Operation, request *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&polls, 1) <= 2 {
+				return nil, errors.New("connection reset")
+			}
+			return next(op, request)
+		}
+	}
+
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithRetryPolicy(RetryPolicy{}),
+		WithMiddleware(failing),
+	)
+
+	handled := false
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		handled = true
+		return nil
+	})
+	run.WatchAndHandle()
+
+	if !handled {
+		t.Fatal("handler is not called after poll errors")
+	}
+}
+
+func TestWatcherStopsForDeletedRun(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	run := NewRun(parsehub, "deleted")
+	run.SetHandler(func(run *Run) error {
+		t.Error("handler of deleted run is called")
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		run.WatchAndHandle()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watching of deleted run is not stopped")
+	}
+}
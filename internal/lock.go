@@ -1,5 +0,0 @@
-package internal
-
-import "sync"
-
-var Lock = sync.RWMutex{}
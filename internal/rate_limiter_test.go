@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter := NewRateLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if waited, err := limiter.Wait(context.Background()); waited != 0 || err != nil {
+			t.Fatalf("request %d of burst waited %s, %v", i, waited, err)
+		}
+	}
+
+	if delay := limiter.reserve(); delay < 900*time.Millisecond {
+		t.Fatalf("expected about 1s delay after burst, got %s", delay)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter := NewRateLimiter(100, 1)
+
+	if waited, _ := limiter.Wait(context.Background()); waited != 0 {
+		t.Fatalf("first request waited %s", waited)
+	}
+
+	waited, err := limiter.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if waited < 5*time.Millisecond || waited > 100*time.Millisecond {
+		t.Fatalf("expected about 10ms wait for refill, got %s", waited)
+	}
+
+	// bucket is refilled up to burst only
+	time.Sleep(50 * time.Millisecond)
+	limiter.reserve()
+	if delay := limiter.reserve(); delay <= 0 {
+		t.Fatal("bucket is refilled over burst")
+	}
+}
+
+func TestRateLimiterReturnsTokenOnCancel(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	limiter.reserve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// cancelled reservation does not delay next caller by one more token
+	if delay := limiter.reserve(); delay > time.Second {
+		t.Fatalf("token of cancelled wait is not returned, delay %s", delay)
+	}
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Replacement of redacted values
+const Redacted = "REDACTED"
+
+var apiKeyParamRegexp = regexp.MustCompile(`(api_key=)[^&\s"'<>]*`)
+
+// Masks secrets and api_key query parameter in strings
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// Registers secret values to mask. Empty values are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+}
+
+// Returns string with masked secrets
+func (r *Redactor) Redact(s string) string {
+	s = apiKeyParamRegexp.ReplaceAllString(s, "${1}"+Redacted)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, Redacted, -1)
+	}
+
+	return s
+}
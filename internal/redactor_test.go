@@ -0,0 +1,28 @@
+package internal
+
+import "testing"
+
+func TestRedactor(t *testing.T) {
+	redactor := Redactor{}
+	redactor.Add("s3cret", "")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"query", "GET https://www.parsehub.com/api/v2/runs/run?api_key=key&format=json", "GET https://www.parsehub.com/api/v2/runs/run?api_key=REDACTED&format=json"},
+		{"form", "api_key=key", "api_key=REDACTED"},
+		{"quoted", `"url": "/run?api_key=key"`, `"url": "/run?api_key=REDACTED"`},
+		{"secret", "webhook/s3cret?x=s3cret", "webhook/REDACTED?x=REDACTED"},
+		{"untouched", "run_token=run", "run_token=run"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if redacted := redactor.Redact(test.input); redacted != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, redacted)
+			}
+		})
+	}
+}
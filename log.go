@@ -3,7 +3,8 @@ package parsehub
 import (
 	"fmt"
 	"log"
-	"os"
+	"strings"
+	"time"
 )
 
 type logLevelType int
@@ -11,37 +12,131 @@ type logLevelType int
 const (
 	LogLevelDebug logLevelType = iota + 1
 	LogLevelWarning
-	LogLevelFatal
+	LogLevelError
 )
 
-var logLevel = LogLevelDebug
-var internalLogger *log.Logger
+// Deprecated: library never terminates process, use LogLevelError.
+const LogLevelFatal = LogLevelError
 
-// Set Logger for package
+// Leveled structured logger.
+// Keyvals are alternating keys and values, e.g. "run_token", token, "status", status.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+var defaultLogger Logger
+
+// Set Logger for clients created without WithLogger option
 func SetLogger(level logLevelType, logger *log.Logger) {
-	internalLogger = logger
-	logLevel = level
+	if logger == nil {
+		defaultLogger = nil
+		return
+	}
+
+	defaultLogger = NewStdLogger(level, logger)
 }
 
-func debugf(s string, args ...interface{}) {
-	if internalLogger != nil && logLevel <= LogLevelDebug {
-		internalLogger.Output(2, fmt.Sprintf("Debug: "+s, args...))
+// Set logger of client
+func WithLogger(logger Logger) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.logger = logger
 	}
+}
+
+// Logger adapter for standard *log.Logger.
+// Writes messages as "Level: msg key=value ...".
+type stdLogger struct {
+	level  logLevelType
+	logger *log.Logger
+}
 
-	return
+// Creates Logger writing to standard logger messages with level or higher
+func NewStdLogger(level logLevelType, logger *log.Logger) Logger {
+	return &stdLogger{level: level, logger: logger}
 }
 
-func warningf(s string, args ...interface{}) {
-	if internalLogger != nil && logLevel <= LogLevelWarning {
-		internalLogger.Output(2, fmt.Sprintf("Warning: "+s, args...))
+func (l *stdLogger) Debug(msg string, keyvals ...interface{}) {
+	l.output(LogLevelDebug, "Debug: ", msg, keyvals)
+}
+
+func (l *stdLogger) Warn(msg string, keyvals ...interface{}) {
+	l.output(LogLevelWarning, "Warning: ", msg, keyvals)
+}
+
+func (l *stdLogger) Error(msg string, keyvals ...interface{}) {
+	l.output(LogLevelError, "Error: ", msg, keyvals)
+}
+
+func (l *stdLogger) output(level logLevelType, prefix string, msg string, keyvals []interface{}) {
+	if l.level > level {
+		return
 	}
 
-	return
+	builder := strings.Builder{}
+	builder.WriteString(prefix)
+	builder.WriteString(msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		builder.WriteString(" ")
+		builder.WriteString(fmt.Sprint(keyvals[i]))
+		builder.WriteString("=")
+
+		if i+1 < len(keyvals) {
+			builder.WriteString(fmt.Sprintf("%+v", keyvals[i+1]))
+		}
+	}
+
+	// caller of client log method
+	l.logger.Output(4, builder.String())
+}
+
+func (parsehub *ParseHub) logDebug(msg string, keyvals ...interface{}) {
+	if logger := parsehub.currentLogger(); logger != nil {
+		logger.Debug(parsehub.redactor.Redact(msg), parsehub.redactKeyvals(keyvals)...)
+	}
+}
+
+func (parsehub *ParseHub) logWarn(msg string, keyvals ...interface{}) {
+	if logger := parsehub.currentLogger(); logger != nil {
+		logger.Warn(parsehub.redactor.Redact(msg), parsehub.redactKeyvals(keyvals)...)
+	}
+}
+
+func (parsehub *ParseHub) logError(msg string, keyvals ...interface{}) {
+	if logger := parsehub.currentLogger(); logger != nil {
+		logger.Error(parsehub.redactor.Redact(msg), parsehub.redactKeyvals(keyvals)...)
+	}
+}
+
+// Returns client logger or package default logger
+func (parsehub *ParseHub) currentLogger() Logger {
+	if parsehub.logger != nil {
+		return parsehub.logger
+	}
+
+	return defaultLogger
 }
 
-func fatalf(s string, args ...interface{}) {
-	if internalLogger != nil && logLevel <= LogLevelFatal {
-		internalLogger.Output(2, fmt.Sprintf("Fatal: "+s, args...))
+// Masks secrets in values. Values other than numbers, bools and durations are converted to strings.
+func (parsehub *ParseHub) redactKeyvals(keyvals []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keyvals))
+
+	for i, value := range keyvals {
+		switch v := value.(type) {
+		case nil, bool, int, int64, uint8, float64, time.Duration:
+			redacted[i] = v
+		case string:
+			redacted[i] = parsehub.redactor.Redact(v)
+		case []byte:
+			redacted[i] = parsehub.redactor.Redact(string(v))
+		case error:
+			redacted[i] = parsehub.redactor.Redact(v.Error())
+		default:
+			redacted[i] = parsehub.redactor.Redact(fmt.Sprintf("%+v", v))
+		}
 	}
-	os.Exit(1)
+
+	return redacted
 }
@@ -0,0 +1,175 @@
+package parsehub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Logger recording messages with keyvals
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+type logEntry struct {
+	level   string
+	msg     string
+	keyvals []interface{}
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.record("debug", msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.record("warn", msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.record("error", msg, keyvals) }
+
+func (l *recordingLogger) record(level string, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, keyvals: keyvals})
+}
+
+func (l *recordingLogger) list() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]logEntry(nil), l.entries...)
+}
+
+// Returns value of key in entry keyvals
+func (entry logEntry) value(key string) (interface{}, bool) {
+	for i := 0; i+1 < len(entry.keyvals); i += 2 {
+		if entry.keyvals[i] == key {
+			return entry.keyvals[i+1], true
+		}
+	}
+
+	return nil, false
+}
+
+// Watch store failing to list records
+type failingWatchStore struct {
+	FileWatchStore
+}
+
+func (store *failingWatchStore) List() ([]WatchRecord, error) {
+	return nil, errors.New("disk is gone")
+}
+
+func TestStdLoggerLevels(t *testing.T) {
+	tests := []struct {
+		level    logLevelType
+		expected []string
+	}{
+		{LogLevelDebug, []string{"Debug: debug", "Warning: warn", "Error: error"}},
+		{LogLevelWarning, []string{"Warning: warn", "Error: error"}},
+		{LogLevelError, []string{"Error: error"}},
+	}
+
+	for _, test := range tests {
+		output := &bytes.Buffer{}
+		logger := NewStdLogger(test.level, log.New(output, "", 0))
+
+		logger.Debug("debug", "operation", OperationGetRun)
+		logger.Warn("warn", "operation", OperationGetRun)
+		logger.Error("error", "operation", OperationGetRun)
+
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		if len(lines) != len(test.expected) {
+			t.Fatalf("level %d: expected %v, got %q", test.level, test.expected, output)
+		}
+
+		for i, line := range lines {
+			if line != test.expected[i]+" operation="+OperationGetRun {
+				t.Fatalf("level %d: expected %q, got %q", test.level, test.expected[i], line)
+			}
+		}
+	}
+}
+
+func TestStdLoggerFormatsKeyvals(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := NewStdLogger(LogLevelDebug, log.New(output, "", 0))
+
+	logger.Warn("Poll", "run_token", "run", "duration", time.Second, "dangling")
+
+	if line := strings.TrimSpace(output.String()); line != "Warning: Poll run_token=run duration=1s dangling=" {
+		t.Fatalf("unexpected line %q", line)
+	}
+}
+
+func TestStdLoggerReportsClientCaller(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	output := &bytes.Buffer{}
+	parsehub := fake.client(WithLogger(NewStdLogger(LogLevelDebug, log.New(output, "", log.Lshortfile))))
+
+	if _, err := parsehub.GetRun("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "log.go:") {
+			t.Fatalf("caller of log adapter is reported instead of client caller: %s", line)
+		}
+	}
+
+	if !strings.HasPrefix(lines[0], "parsehub.go:") || !strings.Contains(output.String(), "request.go:") {
+		t.Fatalf("expected callers in client files, got %s", output)
+	}
+}
+
+func TestLogCallsHaveOperation(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", ProjectToken: "project", Status: RunStatusRunning})
+
+	store, cleanup := newTestWatchStore(t)
+	defer cleanup()
+
+	store.Save(WatchRecord{RunToken: "other", Handler: "unknown", StartedAt: time.Now()})
+
+	logger := &recordingLogger{}
+
+	// store with unknown handler and failing store are resumed
+	fake.client(WithLogger(logger), WithWatchStore(store))
+	fake.client(WithLogger(logger), WithWatchStore(&failingWatchStore{}))
+
+	parsehub := fake.client(WithLogger(logger), WithPollInterval(10*time.Millisecond), WithRetryPolicy(testRetryPolicy))
+	parsehub.GetRun("unknown")
+	NewRun(parsehub, "run").SetHandler(func(run *Run) error { return nil })
+	go NewRun(parsehub, "run").WatchAndHandle()
+	time.Sleep(30 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	parsehub.Close(ctx)
+
+	entries := logger.list()
+	if len(entries) == 0 {
+		t.Fatal("nothing is logged")
+	}
+
+	messages := map[string]bool{}
+	for _, entry := range entries {
+		messages[entry.msg] = true
+
+		if operation, ok := entry.value("operation"); !ok || operation == "" {
+			t.Errorf("%s %q has no operation: %v", entry.level, entry.msg, entry.keyvals)
+		}
+	}
+
+	for _, msg := range []string{"Create new parsehub client", "List watch records error", "Resume run with unknown handler, skip", "Close parsehub client", "Client closed with unhandled runs"} {
+		if !messages[msg] {
+			t.Errorf("message %q is not logged", msg)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package parsehub
+
+import "net/http"
+
+// Names of logical operations passed to middlewares
+const (
+	OperationGetAllProjects    = "ParseHub.GetAllProjects"
+	OperationGetProject        = "ParseHub.GetProject"
+	OperationGetRun            = "ParseHub.GetRun"
+	OperationRunProject        = "Project.Run"
+	OperationLoadLastReadyData = "Project.LoadLastReadyData"
+	OperationLoadRunData       = "Run.LoadData"
+	OperationCancelRun         = "Run.Cancel"
+	OperationDeleteRun         = "Run.Delete"
+)
+
+// Logical operation of ParseHub adapter that sends request
+type Operation struct {
+	// One of Operation* constants
+	Name string
+
+	// Tokens known before request is sent. Empty if unknown.
+	ProjectToken string
+	RunToken     string
+
+	// Number of attempt starting from 1
+	Attempt int
+}
+
+// Sends request to ParseHub and returns response
+type RoundTripFunc func(op Operation, request *http.Request) (*http.Response, error)
+
+// Wraps sending of every request attempt.
+// Middleware can modify request, replace response or return error without calling next.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Add middlewares to client. First middleware is outermost.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.middlewares = append(parsehub.middlewares, middlewares...)
+	}
+}
+
+// Builds round trip chain of client middlewares
+func (parsehub *ParseHub) roundTrip() RoundTripFunc {
+	next := RoundTripFunc(func(op Operation, request *http.Request) (*http.Response, error) {
+		return parsehub.httpClient.Do(request)
+	})
+
+	for i := len(parsehub.middlewares) - 1; i >= 0; i-- {
+		next = parsehub.middlewares[i](next)
+	}
+
+	return next
+}
@@ -0,0 +1,163 @@
+package parsehub
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMiddlewareChainOrder(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", ProjectToken: "project", Status: RunStatusRunning})
+
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+
+	record := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(op Operation, request *http.Request) (*http.Response, error) {
+				mu.Lock()
+				calls = append(calls, name+" "+op.Name+" "+op.RunToken)
+				mu.Unlock()
+
+				request.Header.Set("X-"+name, "1")
+				return next(op, request)
+			}
+		}
+	}
+
+	checkHeaders := func(next RoundTripFunc) RoundTripFunc {
+		return func(op Operation, request *http.Request) (*http.Response, error) {
+			if request.Header.Get("X-outer") == "" || request.Header.Get("X-inner") == "" {
+				t.Error("request is not modified by outer middlewares")
+			}
+			return next(op, request)
+		}
+	}
+
+	parsehub := fake.client(WithMiddleware(record("outer"), record("inner")), WithMiddleware(checkHeaders))
+
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"outer ParseHub.GetRun run", "inner ParseHub.GetRun run"}
+	if strings.Join(calls, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+}
+
+func TestMiddlewareSeesEveryAttempt(t *testing.T) {
+	var requests int32
+	server := newStatusServer(&requests, http.StatusServiceUnavailable, http.StatusOK)
+	defer server.Close()
+
+	var attempts []int
+	parsehub := NewParseHub("test-api-key",
+		WithBaseUrl(server.URL),
+		WithRetryPolicy(testRetryPolicy),
+		WithMiddleware(func(next RoundTripFunc) RoundTripFunc {
+			return func(op Operation, request *http.Request) (*http.Response, error) {
+				attempts = append(attempts, op.Attempt)
+				return next(op, request)
+			}
+		}),
+	)
+
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Fatalf("unexpected attempts %v", attempts)
+	}
+}
+
+func TestMiddlewareShortCircuits(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	errBlocked := errors.New("blocked")
+
+	tests := []struct {
+		name       string
+		middleware Middleware
+		check      func(t *testing.T, run *Run, err error)
+	}{
+		{
+			name: "error",
+			middleware: func(next RoundTripFunc) RoundTripFunc {
+				return func(op Operation, request *http.Request) (*http.Response, error) {
+					return nil, errBlocked
+				}
+			},
+			check: func(t *testing.T, run *Run, err error) {
+				if !errors.Is(err, errBlocked) {
+					t.Fatalf("expected middleware error, got %v", err)
+				}
+			},
+		},
+		{
+			name: "cached response",
+			middleware: func(next RoundTripFunc) RoundTripFunc {
+				return func(op Operation, request *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"run_token":"run","status":"complete"}`)),
+					}, nil
+				}
+			},
+			check: func(t *testing.T, run *Run, err error) {
+				if err != nil || run.GetResponse().Status != RunStatusComplete {
+					t.Fatalf("expected cached response, got %v", err)
+				}
+			},
+		},
+		{
+			name: "nil response",
+			middleware: func(next RoundTripFunc) RoundTripFunc {
+				return func(op Operation, request *http.Request) (*http.Response, error) {
+					return nil, nil
+				}
+			},
+			check: func(t *testing.T, run *Run, err error) {
+				if err == nil {
+					t.Fatal("expected error for nil response")
+				}
+			},
+		},
+		{
+			name: "nil body",
+			middleware: func(next RoundTripFunc) RoundTripFunc {
+				return func(op Operation, request *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusNotFound}, nil
+				}
+			},
+			check: func(t *testing.T, run *Run, err error) {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsehub := fake.client(WithMiddleware(test.middleware), WithRetryPolicy(testRetryPolicy))
+
+			run, err := parsehub.GetRun("run")
+			test.check(t, run, err)
+
+			if count := fake.requestCount(http.MethodGet, "/v2/runs/run"); count != 0 {
+				t.Fatalf("request is sent through short-circuited chain %d times", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package parsehub
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Option configures ParseHub adapter
+type Option func(parsehub *ParseHub)
+
+// Use custom http client for all requests to ParseHub.
+// Useful for timeouts, proxies and TLS configuration.
+func WithHTTPClient(client *http.Client) Option {
+	return func(parsehub *ParseHub) {
+		if client != nil {
+			parsehub.httpClient = client
+		}
+	}
+}
+
+// Override ParseHub API base url. Defaults to BaseUrl.
+func WithBaseUrl(baseUrl string) Option {
+	return func(parsehub *ParseHub) {
+		if baseUrl == "" {
+			return
+		}
+
+		if !strings.HasSuffix(baseUrl, "/") {
+			baseUrl += "/"
+		}
+
+		parsehub.baseUrl = baseUrl
+	}
+}
+
+// Set User-Agent header for all requests to ParseHub
+func WithUserAgent(userAgent string) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.userAgent = userAgent
+	}
+}
+
+// Add default header for all requests to ParseHub
+func WithHeader(key, value string) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.headers.Add(key, value)
+	}
+}
+
+// Mask extra secret values in logs and errors in addition to api key
+func WithSecrets(secrets ...string) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.redactor.Add(secrets...)
+	}
+}
@@ -0,0 +1,90 @@
+package parsehub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Transport counting requests of custom http client
+type countingTransport struct {
+	requests int32
+}
+
+func (transport *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&transport.requests, 1)
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestRequestOptions(t *testing.T) {
+	mu := sync.Mutex{}
+	var requests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+
+		w.Write([]byte(`{"run_token":"run","status":"cancelled"}`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	parsehub := NewParseHub("test-api-key",
+		WithBaseUrl(server.URL+"/api"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithUserAgent("parsehub-test/1.0"),
+		WithHeader("X-Tenant", "acme"),
+		WithHeader("X-Tenant", "globex"),
+		WithRetryPolicy(RetryPolicy{}),
+	)
+
+	run, err := parsehub.GetRun("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := atomic.LoadInt32(&transport.requests); sent != 3 {
+		t.Fatalf("expected 3 requests through custom client, got %d", sent)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/v2/runs/run"},
+		{http.MethodPost, "/api/v2/runs/run/cancel"},
+		{http.MethodDelete, "/api/v2/runs/run"},
+	}
+
+	if len(requests) != len(expected) {
+		t.Fatalf("expected %d requests, got %d", len(expected), len(requests))
+	}
+
+	for i, request := range requests {
+		if request.Method != expected[i].method || request.URL.Path != expected[i].path {
+			t.Fatalf("expected %s %s, got %s %s", expected[i].method, expected[i].path, request.Method, request.URL.Path)
+		}
+
+		if userAgent := request.Header.Get("User-Agent"); userAgent != "parsehub-test/1.0" {
+			t.Errorf("%s: unexpected User-Agent %q", request.Method, userAgent)
+		}
+
+		if tenants := request.Header["X-Tenant"]; len(tenants) != 2 || tenants[0] != "acme" || tenants[1] != "globex" {
+			t.Errorf("%s: unexpected default headers %v", request.Method, tenants)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package parsehub
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
+	"time"
 
 	"github.com/defval/parsehub/internal"
 )
@@ -15,19 +17,68 @@ const (
 
 // ParseHub adapter
 type ParseHub struct {
-	apiKey          string
-	watchQueue      chan *Run
-	projectRegistry map[string]*Project
-	runRegistry     map[string]*Run
+	apiKey      string
+	baseUrl     string
+	userAgent   string
+	headers     http.Header
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+	middlewares []Middleware
+	transport   RoundTripFunc
+	redactor    internal.Redactor
+	logger      Logger
+
+	limiter           *internal.RateLimiter
+	mutatingLimiter   *internal.RateLimiter
+	rateLimitWaitFunc RateLimitWaitFunc
+
+	pollInterval time.Duration
+	watchWorkers int
+	watchContext context.Context
+	watchQueue   chan *watch
+	watcher      *watcher
+
+	handlerRetryPolicy HandlerRetryPolicy
+	deadLetterFunc     DeadLetterFunc
+	deadLetters        deadLetterQueue
+
+	watchStore    WatchStore
+	namedHandlers map[string]RunHandlers
+	dedupStore    DedupStore
+
+	projectRegistry *projectRegistry
+	runRegistry     *runRegistry
 }
 
-// Creates new ParseHub adapter with api key
-func NewParseHub(apiKey string) *ParseHub {
-	debugf("ParseHub: Create new parsehub client with api key: %v", apiKey)
+// Creates new ParseHub adapter with api key and options
+func NewParseHub(apiKey string, opts ...Option) *ParseHub {
 	parsehub := &ParseHub{
 		apiKey:          apiKey,
-		projectRegistry: map[string]*Project{},
-		runRegistry:     map[string]*Run{},
+		baseUrl:         BaseUrl,
+		headers:         http.Header{},
+		httpClient:      http.DefaultClient,
+		retryPolicy:     DefaultRetryPolicy,
+		projectRegistry: newProjectRegistry(),
+		runRegistry:     newRunRegistry(),
+		pollInterval:    DefaultPollInterval,
+		watchWorkers:    DefaultWatchWorkers,
+		watchContext:    context.Background(),
+		watchQueue:      make(chan *watch),
+		dedupStore:      NewMemoryDedupStore(DefaultDedupTTL),
+	}
+
+	for _, opt := range opts {
+		opt(parsehub)
+	}
+
+	parsehub.transport = parsehub.roundTrip()
+	parsehub.watcher = newWatcher(parsehub)
+	parsehub.redactor.Add(apiKey)
+
+	parsehub.logDebug("Create new parsehub client", "operation", "NewParseHub", "api_key", apiKey)
+
+	if parsehub.watchStore != nil {
+		parsehub.resumeWatches()
 	}
 
 	return parsehub
@@ -35,45 +86,42 @@ func NewParseHub(apiKey string) *ParseHub {
 
 // This will return all of the projects in your account
 func (parsehub *ParseHub) GetAllProjects() ([]*Project, error) {
-	requestUrl, _ := url.Parse(BaseUrl + "v2/projects")
-
-	values := url.Values{}
-	values.Add("api_key", parsehub.apiKey)
-
-	requestUrl.RawQuery = values.Encode()
+	return parsehub.GetAllProjectsContext(context.Background())
+}
 
-	if resp, err := http.Get(requestUrl.String()); err != nil {
-		warningf("ParseHub.GetAllProjects: ParseHub HTTP request problem: %s", err.Error())
+// Same as GetAllProjects but request is cancelled with context
+func (parsehub *ParseHub) GetAllProjectsContext(ctx context.Context) ([]*Project, error) {
+	body, err := parsehub.execute(ctx, apiCall{
+		operation:  OperationGetAllProjects,
+		method:     http.MethodGet,
+		path:       "v2/projects",
+		idempotent: true,
+	})
+	if err != nil {
+		parsehub.logWarn("ParseHub request problem", "operation", OperationGetAllProjects, "error", err)
 		return nil, err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
-
-		body, _ := ioutil.ReadAll(resp.Body)
-		debugf("ParseHub.GetAllProjects: Response string: %s", body)
-
-		projectsResponse := &ProjectsResponse{}
-		if err := json.Unmarshal(body, projectsResponse); err != nil {
-			warningf("ParseHub.GetAllProjects: Unmarshal error with body %s", body)
-			return nil, err
-		}
+	}
 
-		projects := []*Project{}
-		var p *Project
+	parsehub.logDebug("Response string", "operation", OperationGetAllProjects, "body", body)
 
-		for _, projectResponse := range projectsResponse.Projects {
-			p = NewProject(parsehub, projectResponse.Token)
-			p.response = projectResponse
-			projects = append(projects, p)
-		}
+	projectsResponse := &ProjectsResponse{}
+	if err := parsehub.decodeResponse("v2/projects", body, projectsResponse); err != nil {
+		parsehub.logWarn("Unmarshal error", "operation", OperationGetAllProjects, "body", body, "error", err)
+		return nil, err
+	}
 
-		debugf("ParseHub.GetAllProjects: Get all projects response: %v", projects)
+	projects := []*Project{}
+	var p *Project
 
-		return projects, nil
-	} else {
-		warningf("ParseHub.GetAllProjects: ParseHub HTTP response problem: %s", err.Error())
-		return nil, err
+	for _, projectResponse := range projectsResponse.Projects {
+		p = NewProject(parsehub, projectResponse.Token)
+		p.setResponse(projectResponse)
+		projects = append(projects, p)
 	}
 
+	parsehub.logDebug("Get all projects response", "operation", OperationGetAllProjects, "count", len(projects))
+
+	return projects, nil
 }
 
 // This will return the project object wrapper for a specific project.
@@ -96,111 +144,157 @@ func (parsehub *ParseHub) GetAllProjects() ([]*Project, error) {
 // If set to anything other than 0, send an email when the run either completes successfully
 // or fails due to an error. Defaults to 0.
 func (parsehub *ParseHub) GetProject(projectToken string) (*Project, error) {
-	debugf("ParseHub.GetProject: Get project with token: %s", projectToken)
-	requestUrl, _ := url.Parse(BaseUrl + "v2/projects/" + projectToken)
-
-	values := url.Values{}
-	values.Add("api_key", parsehub.apiKey)
+	return parsehub.GetProjectContext(context.Background(), projectToken)
+}
 
-	requestUrl.RawQuery = values.Encode()
+// Same as GetProject but request is cancelled with context
+func (parsehub *ParseHub) GetProjectContext(ctx context.Context, projectToken string) (*Project, error) {
+	parsehub.logDebug("Get project", "operation", OperationGetProject, "project_token", projectToken)
+	body, err := parsehub.execute(ctx, apiCall{
+		operation:    OperationGetProject,
+		method:       http.MethodGet,
+		path:         "v2/projects/" + projectToken,
+		idempotent:   true,
+		projectToken: projectToken,
+	})
+	if err != nil {
+		parsehub.logWarn("ParseHub request problem", "operation", OperationGetProject, "project_token", projectToken, "error", err)
+		return nil, err
+	}
 
-	debugf("ParseHub.GetProject: Requested Url: %s", requestUrl)
-	if resp, err := http.Get(requestUrl.String()); err != nil {
-		warningf("ParseHub.GetProject: ParseHub HTTP request problem: %s", err.Error())
+	projectResponse := &ProjectResponse{}
+	if err := parsehub.decodeResponse("v2/projects/"+projectToken, body, projectResponse); err != nil {
+		parsehub.logWarn("Unmarshal error", "operation", OperationGetProject, "project_token", projectToken, "body", body, "error", err)
 		return nil, err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
+	}
 
-		body, _ := ioutil.ReadAll(resp.Body)
-		projectResponse := &ProjectResponse{}
+	project := NewProject(parsehub, projectToken)
 
-		if err := json.Unmarshal(body, projectResponse); err != nil {
-			warningf("ParseHub.GetProject: Unmarshal error with body %s", body)
-			return nil, err
-		}
+	project.setResponse(projectResponse)
 
-		internal.Lock.RLock()
-		project := parsehub.projectRegistry[projectToken]
-		internal.Lock.RUnlock()
+	return project, nil
+}
 
-		debugf("ParseHub.GetProject: Loaded project with token %s from registry: %+v", projectToken, project)
+// This returns the run object wrapper for a given run token.
+func (parsehub *ParseHub) GetRun(runToken string) (*Run, error) {
+	return parsehub.GetRunContext(context.Background(), runToken)
+}
 
-		if project == nil {
-			debugf("ParseHub.GetProject: Need to put new project with token %s into registry", projectToken)
-			project = NewProject(parsehub, projectToken)
+// Same as GetRun but request is cancelled with context
+func (parsehub *ParseHub) GetRunContext(ctx context.Context, runToken string) (*Run, error) {
+	runResponse, err := parsehub.fetchRun(ctx, runToken)
+	if err != nil {
+		return nil, err
+	}
 
-			internal.Lock.RLock()
-			parsehub.projectRegistry[projectToken] = project
-			internal.Lock.RUnlock()
-		}
+	run := NewRun(parsehub, runToken)
 
-		project.response = projectResponse
+	run.setResponse(runResponse)
+
+	return run, nil
+}
 
-		return project, nil
-	} else {
-		warningf("ParseHub.GetProject: ParseHub HTTP response problem: %s", err.Error())
+// Returns run data without registering run
+func (parsehub *ParseHub) fetchRun(ctx context.Context, runToken string) (*RunResponse, error) {
+	parsehub.logDebug("Get run", "operation", OperationGetRun, "run_token", runToken)
+	body, err := parsehub.execute(ctx, apiCall{
+		operation:  OperationGetRun,
+		method:     http.MethodGet,
+		path:       "v2/runs/" + runToken,
+		idempotent: true,
+		runToken:   runToken,
+	})
+	if err != nil {
+		parsehub.logWarn("ParseHub request problem", "operation", OperationGetRun, "run_token", runToken, "error", err)
 		return nil, err
 	}
-}
 
-// This returns the run object wrapper for a given run token.
-func (parsehub *ParseHub) GetRun(runToken string) (*Run, error) {
-	debugf("ParseHub.GetRun: Get run with token %s", runToken)
-	requestUrl, _ := url.Parse(BaseUrl + "v2/runs/" + runToken)
+	parsehub.logDebug("Response string", "operation", OperationGetRun, "run_token", runToken, "body", body)
+
+	runResponse := &RunResponse{}
+	if err := parsehub.decodeResponse("v2/runs/"+runToken, body, runResponse); err != nil {
+		parsehub.logWarn("Unmarshal error", "operation", OperationGetRun, "run_token", runToken, "body", body, "error", err)
+		return nil, err
+	}
 
-	values := url.Values{}
-	values.Add("api_key", parsehub.apiKey)
+	parsehub.logDebug("Run response", "operation", OperationGetRun, "project_token", runResponse.ProjectToken, "run_token", runToken, "status", runResponse.Status)
 
-	requestUrl.RawQuery = values.Encode()
+	return runResponse, nil
+}
 
-	if resp, err := http.Get(requestUrl.String()); err != nil {
-		warningf("ParseHub.GetRun: ParseHub HTTP request problem: %s", err.Error())
+// Loads run from string
+// Example from webhook post body in JSON or form encoding.
+// Returns registered run updated with body data. Handler set on finished run is called,
+// failed handler is put into dead letter queue. Close waits for called handler.
+// Finished run without handler is removed from registry.
+// Returns run with handler error if handler failed after all retries, webhook should
+// be answered with error status then. Returns ErrClosed after client is closed.
+func (parsehub *ParseHub) LoadRunFromBytes(body []byte) (*Run, error) {
+	return parsehub.loadRun(context.Background(), "ParseHub.LoadRunFromBytes", "", body)
+}
+
+// Same as LoadRunFromBytes but run is loaded from webhook request.
+// Body is decoded according to Content-Type header.
+// Returns ErrBodyTooLarge if body is larger than DefaultWebhookMaxBodyBytes.
+func (parsehub *ParseHub) LoadRunFromRequest(request *http.Request) (*Run, error) {
+	// one byte over limit detects too large body
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, DefaultWebhookMaxBodyBytes+1))
+	if err != nil {
+		parsehub.logWarn("Read request body error", "operation", "ParseHub.LoadRunFromRequest", "error", err)
 		return nil, err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
+	}
 
-		body, _ := ioutil.ReadAll(resp.Body)
+	if len(body) > DefaultWebhookMaxBodyBytes {
+		err := fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, DefaultWebhookMaxBodyBytes)
+		parsehub.logWarn("Read request body error", "operation", "ParseHub.LoadRunFromRequest", "error", err)
+		return nil, err
+	}
 
-		debugf("ParseHub.GetRun: Response string for run %s: %s", runToken, body)
+	contentType := request.Header.Get("Content-Type")
 
-		runResponse := &RunResponse{}
-		if err := json.Unmarshal(body, runResponse); err != nil {
-			warningf("ParseHub.GetRun: Problem with unmarshal json string: %s", body)
-			return nil, err
-		}
+	// body is already consumed by ParseForm
+	if len(body) == 0 && len(request.PostForm) > 0 {
+		body = []byte(request.PostForm.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
 
-		debugf("ParseHub.GetRun: Run response: %+v", runResponse)
+	return parsehub.loadRun(request.Context(), "ParseHub.LoadRunFromRequest", contentType, body)
+}
 
-		internal.Lock.RLock()
-		run := parsehub.runRegistry[runToken]
-		internal.Lock.RUnlock()
+func (parsehub *ParseHub) loadRun(ctx context.Context, operation string, contentType string, body []byte) (*Run, error) {
+	if parsehub.watcher.isClosed() {
+		return nil, ErrClosed
+	}
 
-		if run == nil {
-			run = NewRun(parsehub, runToken)
-			internal.Lock.Lock()
-			parsehub.runRegistry[runToken] = run
-			internal.Lock.Unlock()
-		}
+	runResponse, err := decodeWebhook(contentType, body)
+	if err != nil {
+		parsehub.logWarn("Unmarshal error", "operation", operation, "body", body, "error", err)
+		return nil, parsehub.redactError(err)
+	}
 
-		run.response = runResponse // update response
+	run := parsehub.updateWebhookRun(runResponse)
 
+	snapshot := run.GetResponse()
+	if !snapshot.Status.IsTerminal() {
 		return run, nil
-	} else {
-		warningf("ParseHub.GetRun: ParseHub HTTP response problem: %s", err.Error())
-		return nil, err
 	}
-}
 
-// Loads run from string
-// Example from webhook post body
-func (parsehub *ParseHub) LoadRunFromBytes(body []byte) (*Run, error) {
-	runResponse := &RunResponse{}
+	handleFunc := run.getHandlers().handler(snapshot)
+	if handleFunc == nil {
+		// finished run is not updated anymore, loaded runs do not grow registry
+		parsehub.runRegistry.delete(run)
+		parsehub.deleteWatch(run)
+		return run, nil
+	}
 
-	if err := json.Unmarshal(body, runResponse); err != nil {
-		warningf("NewRunFromString: Problem with unmarshal json string: %s", body)
-		return nil, err
+	if !parsehub.watcher.startCall(run) {
+		return nil, ErrClosed
+	}
+	defer parsehub.watcher.finishCall(run)
+
+	if err := parsehub.callHandler(ctx, operation, run, handleFunc); err != nil {
+		return run, err
 	}
 
-	run := NewRun(parsehub, runResponse.RunToken)
 	return run, nil
 }
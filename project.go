@@ -1,11 +1,14 @@
 package parsehub
 
 import (
+	"context"
 	"encoding/json"
-	"github.com/defval/parsehub/internal"
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 )
 
 // Project run params
@@ -14,38 +17,112 @@ type ProjectRunParams struct {
 	StartTemplate      string
 	StartValueOverride map[string]interface{}
 	SendEmail          bool
+
+	// Limits of watched run, not sent to ParseHub
+	Guards RunGuards
+}
+
+// Validates params and returns *ValidationError with name of bad field
+func (params ProjectRunParams) Validate() error {
+	_, err := params.encode()
+	return err
+}
+
+// Encodes params to request values
+func (params ProjectRunParams) encode() (url.Values, error) {
+	values := url.Values{}
+
+	if params.StartUrl != "" {
+		startUrl, err := url.Parse(params.StartUrl)
+		if err != nil {
+			return nil, &ValidationError{Field: "StartUrl", Err: err}
+		}
+
+		if !startUrl.IsAbs() || startUrl.Host == "" {
+			return nil, &ValidationError{Field: "StartUrl", Err: errors.New("url must be absolute")}
+		}
+
+		values.Add("start_url", params.StartUrl)
+	}
+
+	if params.StartTemplate != "" {
+		values.Add("start_template", params.StartTemplate)
+	}
+
+	if len(params.StartValueOverride) != 0 {
+		bytes, err := json.Marshal(params.StartValueOverride)
+		if err != nil {
+			return nil, &ValidationError{Field: "StartValueOverride", Err: err}
+		}
+
+		values.Add("start_value_override", string(bytes))
+	}
+
+	if params.SendEmail {
+		values.Add("send_email", "1")
+	}
+
+	if err := params.Guards.Validate(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
 }
 
 // ParseHub project Wrapper
 type Project struct {
 	parsehub *ParseHub
-
 	token    string
-	response *ProjectResponse
+
+	mu       sync.RWMutex
+	snapshot *ProjectSnapshot
 }
 
-// Creates new parsehub project wrapper
+// Returns parsehub project wrapper registered in client or creates and registers new one
 func NewProject(parsehub *ParseHub, token string) *Project {
-	project := parsehub.projectRegistry[token]
-
-	if project == nil {
-		project = &Project{
+	return parsehub.projectRegistry.getOrCreate(token, func() *Project {
+		return &Project{
 			parsehub: parsehub,
 			token:    token,
 		}
-	}
+	})
+}
+
+// Get snapshot of project data. Returns nil if project data was not loaded yet.
+// Compare Version of snapshots to find out which one is fresher.
+func (p *Project) GetResponse() *ProjectSnapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 
-	return project
+	return p.snapshot.clone()
 }
 
-// Get project data
-func (p *Project) GetResponse() *ProjectResponse {
-	return p.response
+// Replaces project data with new snapshot
+func (p *Project) setResponse(response *ProjectResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := &ProjectSnapshot{
+		ProjectResponse: *response,
+		Version:         1,
+		FetchedAt:       time.Now(),
+	}
+
+	if p.snapshot != nil {
+		snapshot.Version = p.snapshot.Version + 1
+	}
+
+	p.snapshot = snapshot
 }
 
 // Refresh project data
 func (p *Project) Refresh() error {
-	_, err := p.parsehub.GetProject(p.token)
+	return p.RefreshContext(context.Background())
+}
+
+// Same as Refresh but request is cancelled with context
+func (p *Project) RefreshContext(ctx context.Context) error {
+	_, err := p.parsehub.GetProjectContext(ctx, p.token)
 	return err
 }
 
@@ -70,107 +147,133 @@ func (p *Project) Refresh() error {
 // If set to anything other than 0, send an email when the run either completes successfully or
 // fails due to an error. Defaults to 0.
 func (p *Project) Run(params ProjectRunParams, handleFunc HandleRunFunc) (*Run, error) {
-	debugf(
-		"Project.Run: Run project %s with params: %+v",
-		p.token,
-		params,
-	)
+	return p.RunContext(context.Background(), params, handleFunc)
+}
 
-	requestUrl, _ := url.Parse(BaseUrl + "v2/projects/" + p.token + "/run")
+// Same as Run but start request is cancelled with context.
+//
+// Context bounds only the start request. Started run is watched with client watch context
+// (see WithWatchContext) until it is finished or client is closed, so handler is called
+// even if context is done right after the run is started. Handler can get watch context with Run.Context.
+func (p *Project) RunContext(ctx context.Context, params ProjectRunParams, handleFunc HandleRunFunc) (*Run, error) {
+	if handleFunc == nil {
+		return p.RunWithHandlers(ctx, params, RunHandlers{})
+	}
 
-	values := url.Values{}
-	values.Add("api_key", p.parsehub.apiKey)
+	return p.RunWithHandlers(ctx, params, handlersOf(handleFunc))
+}
 
-	if params.StartUrl != "" {
-		values.Add("start_url", params.StartUrl)
-	}
+// Same as RunContext but finished run is handled by handler of its outcome
+func (p *Project) RunWithHandlers(ctx context.Context, params ProjectRunParams, handlers RunHandlers) (*Run, error) {
+	return p.run(ctx, params, handlers, "", nil)
+}
 
-	if params.StartTemplate != "" {
-		values.Add("start_template", params.StartTemplate)
+// Same as RunWithHandlers but run is handled by handlers registered with WithNamedHandlers.
+// Run is saved to watch store with handler name and metadata and resumed after restart.
+func (p *Project) RunWithNamedHandler(ctx context.Context, params ProjectRunParams, name string, metadata map[string]string) (*Run, error) {
+	handlers, ok := p.parsehub.namedHandler(name)
+	if !ok {
+		return nil, &ValidationError{Field: "handler", Err: fmt.Errorf("handler %q is not registered", name)}
 	}
 
-	if len(params.StartValueOverride) != 0 {
-		if bytes, err := json.Marshal(params.StartValueOverride); err != nil {
-			fatalf("Project.Run: Incorrect StartValueOverride")
-		} else {
-			values.Add("start_value_override", string(bytes))
-		}
+	return p.run(ctx, params, handlers, name, metadata)
+}
+
+func (p *Project) run(ctx context.Context, params ProjectRunParams, handlers RunHandlers, handlerName string, metadata map[string]string) (*Run, error) {
+	p.parsehub.logDebug(
+		"Run project",
+		"operation", OperationRunProject,
+		"project_token", p.token,
+		"params", params,
+	)
+
+	values, err := params.encode()
+	if err != nil {
+		p.parsehub.logError("Invalid params", "operation", OperationRunProject, "project_token", p.token, "error", err)
+		return nil, err
 	}
 
-	if params.SendEmail {
-		values.Add("send_email", "1")
+	// started run would not be handled by closed client
+	if !handlers.empty() && p.parsehub.watcher.isClosed() {
+		return nil, ErrClosed
 	}
 
-	if resp, err := http.PostForm(requestUrl.String(), values); err != nil {
-		warningf("Project.Run: ParseHub HTTP request problem: %s", err.Error())
+	body, err := p.parsehub.execute(ctx, apiCall{
+		operation:    OperationRunProject,
+		method:       http.MethodPost,
+		path:         "v2/projects/" + p.token + "/run",
+		values:       values,
+		mutating:     true,
+		projectToken: p.token,
+	})
+	if err != nil {
+		p.parsehub.logWarn("ParseHub request problem", "operation", OperationRunProject, "project_token", p.token, "error", err)
 		return nil, err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
-
-		body, _ := ioutil.ReadAll(resp.Body)
-		runResponse := &RunResponse{}
-		if err := json.Unmarshal(body, runResponse); err != nil {
-			warningf("Project.Run: Unmarshal error with body %s", body)
-			return nil, err
-		}
+	}
 
-		internal.Lock.RLock()
-		run := p.parsehub.runRegistry[runResponse.RunToken]
-		internal.Lock.RUnlock()
+	runResponse := &RunResponse{}
+	if err := p.parsehub.decodeResponse("v2/projects/"+p.token+"/run", body, runResponse); err != nil {
+		p.parsehub.logWarn("Unmarshal error", "operation", OperationRunProject, "project_token", p.token, "body", body, "error", err)
+		return nil, err
+	}
 
-		if run == nil {
-			run = NewRun(p.parsehub, runResponse.RunToken)
-			internal.Lock.Lock()
-			p.parsehub.runRegistry[runResponse.RunToken] = run
-			internal.Lock.Unlock()
-		}
+	startedAt := time.Now()
 
-		run.response = runResponse
+	run := NewRun(p.parsehub, runResponse.RunToken)
+	run.setResponse(runResponse)
+	run.setStartedAt(startedAt)
 
-		run.SetHandler(handleFunc)
+	run.SetHandlers(handlers)
+	run.SetGuards(params.Guards)
 
-		internal.Lock.Lock()
-		p.parsehub.runRegistry[run.token] = run
-		internal.Lock.Unlock()
+	if handlerName != "" {
+		run.setNamedHandler(handlerName, metadata)
+		p.parsehub.saveWatch(WatchRecord{
+			RunToken:     run.token,
+			ProjectToken: p.token,
+			Handler:      handlerName,
+			Metadata:     metadata,
+			Guards:       params.Guards,
+			StartedAt:    startedAt,
+		})
+	}
 
-		// watch only with handler
-		if handleFunc != nil {
-			debugf("Project.Run: Start WatchAndHandle for run with token %s", run.token)
-			go run.WatchAndHandle()
+	// watch only with handler
+	if !handlers.empty() {
+		p.parsehub.logDebug("Start WatchAndHandle", "operation", OperationRunProject, "project_token", p.token, "run_token", run.token)
+		if p.parsehub.watcher.watch(p.parsehub.watchContext, run) == nil {
+			p.parsehub.logWarn("Watching double run or closed client", "operation", OperationRunProject, "run_token", run.token)
 		}
-
-		return run, nil
-	} else {
-		warningf("Project.Run: ParseHub HTTP response problem: %s", err.Error())
-		return nil, err
 	}
+
+	return run, nil
 }
 
 // This returns the data for the most recent ready run for a project.
 // You can use this method in order to have a synchronous interface to your project.
 func (p *Project) LoadLastReadyData(target interface{}) error {
-	debugf("Project.LoadLastReadyData: Load: %s", p.token)
-	requestUrl, _ := url.Parse(BaseUrl + "v2/projects/" + p.token + "/last_ready_run/data")
-
-	values := url.Values{}
-	values.Add("api_key", p.parsehub.apiKey)
-
-	requestUrl.RawQuery = values.Encode()
+	return p.LoadLastReadyDataContext(context.Background(), target)
+}
 
-	if resp, err := http.Get(requestUrl.String()); err != nil {
-		warningf("Project.LoadLastReadyData: ParseHub HTTP request problem: %s", err.Error())
+// Same as LoadLastReadyData but request is cancelled with context
+func (p *Project) LoadLastReadyDataContext(ctx context.Context, target interface{}) error {
+	p.parsehub.logDebug("Load last ready data", "operation", OperationLoadLastReadyData, "project_token", p.token)
+	body, err := p.parsehub.execute(ctx, apiCall{
+		operation:    OperationLoadLastReadyData,
+		method:       http.MethodGet,
+		path:         "v2/projects/" + p.token + "/last_ready_run/data",
+		idempotent:   true,
+		projectToken: p.token,
+	})
+	if err != nil {
+		p.parsehub.logWarn("ParseHub request problem", "operation", OperationLoadLastReadyData, "project_token", p.token, "error", err)
 		return err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
+	}
 
-		body, _ := ioutil.ReadAll(resp.Body)
-		if err := json.Unmarshal(body, target); err != nil {
-			warningf("Project.LoadLastReadyData: Unmarshal error with body %s", body)
-			return err
-		}
-		return nil
-	} else {
-		warningf("Project.LoadLastReadyData: ParseHub HTTP response problem: %s", err.Error())
+	if err := p.parsehub.decodeResponse("v2/projects/"+p.token+"/last_ready_run/data", body, target); err != nil {
+		p.parsehub.logWarn("Unmarshal error", "operation", OperationLoadLastReadyData, "project_token", p.token, "body", body, "error", err)
 		return err
 	}
+
+	return nil
 }
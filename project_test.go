@@ -0,0 +1,95 @@
+package parsehub
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestProjectRunParamsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params ProjectRunParams
+		field  string
+	}{
+		{"empty", ProjectRunParams{}, ""},
+		{"absolute url", ProjectRunParams{StartUrl: "https://www.example.com/search?q=x"}, ""},
+		{"relative url", ProjectRunParams{StartUrl: "/search"}, "StartUrl"},
+		{"url without host", ProjectRunParams{StartUrl: "mailto:user@example.com"}, "StartUrl"},
+		{"malformed url", ProjectRunParams{StartUrl: "http://[::1"}, "StartUrl"},
+		{"channel start value", ProjectRunParams{StartValueOverride: map[string]interface{}{"query": make(chan int)}}, "StartValueOverride"},
+		{"func start value", ProjectRunParams{StartValueOverride: map[string]interface{}{"query": func() {}}}, "StartValueOverride"},
+		{"negative guard", ProjectRunParams{Guards: RunGuards{MaxDuration: -time.Second}}, "Guards.MaxDuration"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.params.Validate()
+
+			if test.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != test.field {
+				t.Fatalf("expected *ValidationError of %s, got %v", test.field, err)
+			}
+
+			if !errors.Is(err, ErrInvalidParams) {
+				t.Fatalf("validation error does not match ErrInvalidParams, got %v", err)
+			}
+		})
+	}
+}
+
+func TestProjectRunParamsEncode(t *testing.T) {
+	values, err := ProjectRunParams{
+		StartUrl:           "https://www.example.com",
+		StartTemplate:      "main_template",
+		StartValueOverride: map[string]interface{}{"query": "San Francisco"},
+		SendEmail:          true,
+		Guards:             RunGuards{MaxPages: 10},
+	}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"start_url":            "https://www.example.com",
+		"start_template":       "main_template",
+		"start_value_override": `{"query":"San Francisco"}`,
+		"send_email":           "1",
+	}
+
+	if len(values) != len(expected) {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	for key, value := range expected {
+		if values.Get(key) != value {
+			t.Fatalf("expected %s=%s, got %v", key, value, values)
+		}
+	}
+}
+
+func TestProjectRunRejectsInvalidParams(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	parsehub := fake.client()
+
+	_, err := NewProject(parsehub, "project").Run(ProjectRunParams{
+		StartValueOverride: map[string]interface{}{"callback": func() {}},
+	}, nil)
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+
+	if count := fake.requestCount(http.MethodPost, "/v2/projects/project/run"); count != 0 {
+		t.Fatalf("request with invalid params is sent %d times", count)
+	}
+}
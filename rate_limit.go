@@ -0,0 +1,74 @@
+package parsehub
+
+import (
+	"context"
+	"time"
+
+	"github.com/defval/parsehub/internal"
+)
+
+// Client-side rate limit of requests to ParseHub
+type RateLimit struct {
+	// Average number of requests per second. Zero disables limiting.
+	RequestsPerSecond float64
+
+	// Maximum number of requests sent at once. Defaults to 1.
+	Burst int
+}
+
+// Called after request waited in rate limiter with logical operation name and waited duration
+type RateLimitWaitFunc func(operation string, waited time.Duration)
+
+// Limit all requests of client. Mutating requests use this limit
+// only if WithMutatingRateLimit is not set.
+func WithRateLimit(limit RateLimit) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.limiter = newRateLimiter(limit)
+	}
+}
+
+// Limit mutating requests (run, cancel and delete) with separate budget
+func WithMutatingRateLimit(limit RateLimit) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.mutatingLimiter = newRateLimiter(limit)
+	}
+}
+
+// Report time spent by requests in rate limiter
+func WithRateLimitWaitFunc(waitFunc RateLimitWaitFunc) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.rateLimitWaitFunc = waitFunc
+	}
+}
+
+func newRateLimiter(limit RateLimit) *internal.RateLimiter {
+	if limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	return internal.NewRateLimiter(limit.RequestsPerSecond, limit.Burst)
+}
+
+// Waits in rate limiter of api call
+func (parsehub *ParseHub) waitRateLimit(ctx context.Context, call apiCall) error {
+	limiter := parsehub.limiter
+	if call.mutating && parsehub.mutatingLimiter != nil {
+		limiter = parsehub.mutatingLimiter
+	}
+
+	if limiter == nil {
+		return nil
+	}
+
+	waited, err := limiter.Wait(ctx)
+
+	if waited > 0 {
+		parsehub.logDebug("Waited in rate limiter", "operation", call.operation, "project_token", call.projectToken, "run_token", call.runToken, "duration", waited)
+
+		if parsehub.rateLimitWaitFunc != nil {
+			parsehub.rateLimitWaitFunc(call.operation, waited)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,86 @@
+package parsehub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSeparateMutatingBudget(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusRunning})
+
+	var (
+		mu     sync.Mutex
+		waited = map[string]time.Duration{}
+	)
+
+	parsehub := fake.client(
+		WithRateLimit(RateLimit{RequestsPerSecond: 20, Burst: 1}),
+		WithMutatingRateLimit(RateLimit{RequestsPerSecond: 1, Burst: 1}),
+		WithRateLimitWaitFunc(func(operation string, duration time.Duration) {
+			mu.Lock()
+			waited[operation] += duration
+			mu.Unlock()
+		}),
+	)
+
+	// mutating request takes only mutating token
+	if err := NewRun(parsehub, "run").Cancel(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	// read budget is refilled after 50ms
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if waited[OperationCancelRun] != 0 {
+		t.Fatalf("first mutating request waited %s", waited[OperationCancelRun])
+	}
+
+	if wait := waited[OperationGetRun]; wait < 20*time.Millisecond || wait > 500*time.Millisecond {
+		t.Fatalf("expected about 50ms wait of second read, got %s", wait)
+	}
+}
+
+func TestRateLimitMutatingUsesSharedBudgetByDefault(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusRunning})
+
+	reported := make(chan string, 2)
+	parsehub := fake.client(
+		WithRateLimit(RateLimit{RequestsPerSecond: 20, Burst: 1}),
+		WithRateLimitWaitFunc(func(operation string, duration time.Duration) {
+			reported <- operation
+		}),
+	)
+
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewRun(parsehub, "run").Cancel(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case operation := <-reported:
+		if operation != OperationCancelRun {
+			t.Fatalf("unexpected waiting operation %s", operation)
+		}
+	default:
+		t.Fatal("mutating request did not wait in shared limiter")
+	}
+}
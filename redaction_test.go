@@ -0,0 +1,156 @@
+package parsehub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/defval/parsehub/internal"
+)
+
+const (
+	testSecretKey    = "k3y-0f-t3st"
+	testSecretExtra  = "extra-s3cret"
+	testSecretHidden = "api_key=" + internal.Redacted
+)
+
+// Fails test if text contains api key, extra secret or api_key parameter with value
+func assertRedacted(t *testing.T, name string, text string) {
+	t.Helper()
+
+	if strings.Contains(text, testSecretKey) || strings.Contains(text, testSecretExtra) {
+		t.Fatalf("%s contains secret: %s", name, text)
+	}
+
+	if strings.Contains(strings.Replace(text, testSecretHidden, "", -1), "api_key=") {
+		t.Fatalf("%s contains api_key parameter: %s", name, text)
+	}
+}
+
+// Server echoing request url and body in response with status
+func newEchoServer(statusCode int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.WriteHeader(statusCode)
+		fmt.Fprintf(w, "request %s form %s secret %s", r.URL, r.PostForm.Encode(), testSecretExtra)
+	}))
+}
+
+func TestRedactionInLogs(t *testing.T) {
+	server := newEchoServer(http.StatusBadRequest)
+	defer server.Close()
+
+	output := &bytes.Buffer{}
+	parsehub := NewParseHub(testSecretKey,
+		WithBaseUrl(server.URL),
+		WithRetryPolicy(testRetryPolicy),
+		WithSecrets(testSecretExtra),
+		WithLogger(NewStdLogger(LogLevelDebug, log.New(output, "", 0))),
+	)
+
+	if _, err := parsehub.GetRun("run"); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+
+	if _, err := NewProject(parsehub, "project").Run(ProjectRunParams{StartTemplate: testSecretExtra}, nil); err == nil {
+		t.Fatal("expected error of run")
+	}
+
+	if output.Len() == 0 || !strings.Contains(output.String(), internal.Redacted) {
+		t.Fatalf("nothing is redacted in logs: %s", output)
+	}
+
+	assertRedacted(t, "log", output.String())
+}
+
+func TestRedactionInErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		check      func(t *testing.T, err error)
+	}{
+		{
+			name:       "api error",
+			statusCode: http.StatusForbidden,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || !errors.Is(err, ErrForbidden) {
+					t.Fatalf("expected *APIError, got %v", err)
+				}
+				assertRedacted(t, "APIError.Body", apiErr.Body)
+			},
+		},
+		{
+			name:       "decode error",
+			statusCode: http.StatusOK,
+			check: func(t *testing.T, err error) {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("expected *DecodeError, got %v", err)
+				}
+				assertRedacted(t, "DecodeError.Body", decodeErr.Body)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newEchoServer(test.statusCode)
+			defer server.Close()
+
+			parsehub := NewParseHub(testSecretKey, WithBaseUrl(server.URL), WithSecrets(testSecretExtra))
+
+			_, err := parsehub.GetRun("run")
+			test.check(t, err)
+			assertRedacted(t, "error", err.Error())
+		})
+	}
+}
+
+func TestRedactionInURLError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	baseUrl := server.URL
+	server.Close()
+
+	parsehub := NewParseHub(testSecretKey, WithBaseUrl(baseUrl), WithRetryPolicy(RetryPolicy{}))
+
+	_, err := parsehub.GetRun("run")
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("expected *url.Error, got %T: %v", err, err)
+	}
+
+	assertRedacted(t, "url.Error.URL", urlErr.URL)
+	assertRedacted(t, "error", err.Error())
+}
+
+func TestRedactionOfMiddlewareError(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	errProxy := errors.New("proxy refused")
+	parsehub := NewParseHub(testSecretKey,
+		WithBaseUrl(fake.URL),
+		WithRetryPolicy(RetryPolicy{}),
+		WithSecrets(testSecretExtra),
+		WithMiddleware(func(next RoundTripFunc) RoundTripFunc {
+			return func(op Operation, request *http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("%s with %s: %w", request.URL, testSecretExtra, errProxy)
+			}
+		}),
+	)
+
+	_, err := parsehub.GetRun("run")
+	if !errors.Is(err, errProxy) {
+		t.Fatalf("redacted error does not wrap middleware error, got %v", err)
+	}
+
+	assertRedacted(t, "error", err.Error())
+}
@@ -0,0 +1,85 @@
+package parsehub
+
+import "sync"
+
+// Projects of client by token
+type projectRegistry struct {
+	mu       sync.Mutex
+	projects map[string]*Project
+}
+
+func newProjectRegistry() *projectRegistry {
+	return &projectRegistry{projects: map[string]*Project{}}
+}
+
+// Returns registered project or nil
+func (registry *projectRegistry) get(token string) *Project {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	return registry.projects[token]
+}
+
+// Returns registered project or atomically registers new one created by create
+func (registry *projectRegistry) getOrCreate(token string, create func() *Project) *Project {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	project := registry.projects[token]
+	if project == nil {
+		project = create()
+		registry.projects[token] = project
+	}
+
+	return project
+}
+
+// Runs of client by token
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*Run
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{runs: map[string]*Run{}}
+}
+
+// Returns registered run or nil
+func (registry *runRegistry) get(token string) *Run {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	return registry.runs[token]
+}
+
+// Returns registered run or atomically registers new one created by create
+func (registry *runRegistry) getOrCreate(token string, create func() *Run) *Run {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	run := registry.runs[token]
+	if run == nil {
+		run = create()
+		registry.runs[token] = run
+	}
+
+	return run
+}
+
+// Removes run from registry if it is the registered one
+func (registry *runRegistry) delete(run *Run) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.runs[run.token] == run {
+		delete(registry.runs, run.token)
+	}
+}
+
+// Returns number of registered runs
+func (registry *runRegistry) len() int {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	return len(registry.runs)
+}
@@ -0,0 +1,135 @@
+package parsehub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewRunReturnsSameRunConcurrently(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	const goroutines = 50
+	runs := make([]*Run, goroutines)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runs[i] = NewRun(parsehub, "run")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, run := range runs {
+		if run != runs[0] {
+			t.Fatalf("run %d differs from first run", i)
+		}
+	}
+
+	if parsehub.runRegistry.len() != 1 {
+		t.Fatalf("expected 1 registered run, got %d", parsehub.runRegistry.len())
+	}
+}
+
+func TestNewProjectReturnsSameProjectConcurrently(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	const goroutines = 50
+	projects := make([]*Project, goroutines)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			projects[i] = NewProject(parsehub, "project")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, project := range projects {
+		if project != projects[0] {
+			t.Fatalf("project %d differs from first project", i)
+		}
+	}
+}
+
+func TestRegistriesConcurrentAccess(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	parsehub := fake.client()
+
+	const goroutines = 20
+	errs := make(chan error, goroutines*4)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			project, err := parsehub.GetProject(fmt.Sprintf("project-%d", i))
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			run, err := project.Run(ProjectRunParams{}, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if got, err := parsehub.GetRun(run.token); err != nil {
+				errs <- err
+				return
+			} else if got != run {
+				errs <- fmt.Errorf("GetRun returned unregistered run %s", run.token)
+				return
+			}
+
+			if err := run.Delete(); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatalf("expected deleted runs to be removed from registry, got %d", parsehub.runRegistry.len())
+	}
+}
+
+func TestWaitedRunIsRemovedFromRegistry(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusComplete})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// run without handler is watched only by Wait
+	if _, err := NewRun(parsehub, "run").Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatalf("finished run without handler is kept in registry, got %d runs", parsehub.runRegistry.len())
+	}
+}
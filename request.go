@@ -0,0 +1,216 @@
+package parsehub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Call of ParseHub API endpoint
+type apiCall struct {
+	// Logical operation name, one of Operation* constants
+	operation string
+	method    string
+	path      string
+	values    url.Values
+
+	// Tokens passed to middlewares
+	projectToken string
+	runToken     string
+
+	// Call can be safely repeated without side effects
+	idempotent bool
+
+	// Call changes state of runs and uses mutating rate limit
+	mutating bool
+}
+
+// Creates request to ParseHub API with api key bound to context.
+// Values are sent as query for GET and DELETE and as form body for other methods.
+func (parsehub *ParseHub) newRequest(ctx context.Context, method string, path string, values url.Values) (*http.Request, error) {
+	requestUrl, err := url.Parse(parsehub.baseUrl + path)
+	if err != nil {
+		return nil, err
+	}
+
+	if values == nil {
+		values = url.Values{}
+	}
+	values.Set("api_key", parsehub.apiKey)
+
+	var request *http.Request
+
+	switch method {
+	case http.MethodGet, http.MethodDelete:
+		requestUrl.RawQuery = values.Encode()
+		request, err = http.NewRequest(method, requestUrl.String(), nil)
+	default:
+		request, err = http.NewRequest(method, requestUrl.String(), strings.NewReader(values.Encode()))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, headerValues := range parsehub.headers {
+		for _, value := range headerValues {
+			request.Header.Add(key, value)
+		}
+	}
+
+	if parsehub.userAgent != "" {
+		request.Header.Set("User-Agent", parsehub.userAgent)
+	}
+
+	return request.WithContext(ctx), nil
+}
+
+// Sends request attempt through middlewares and configured http client.
+// Returns error instead of nil response of misbehaving middleware.
+func (parsehub *ParseHub) do(call apiCall, attempt int, request *http.Request) (*http.Response, error) {
+	resp, err := parsehub.transport(Operation{
+		Name:         call.operation,
+		ProjectToken: call.projectToken,
+		RunToken:     call.runToken,
+		Attempt:      attempt,
+	}, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		return nil, fmt.Errorf("parsehub: %s %s: round trip returned nil response without error", call.method, call.path)
+	}
+
+	if resp.Body == nil {
+		resp.Body = http.NoBody
+	}
+
+	return resp, nil
+}
+
+// Executes api call and returns response body. Secrets are masked in returned error.
+func (parsehub *ParseHub) execute(ctx context.Context, call apiCall) ([]byte, error) {
+	start := time.Now()
+
+	body, err := parsehub.executeWithRetry(ctx, call)
+	if err != nil {
+		err = parsehub.redactError(err)
+		parsehub.logDebug(
+			"Request failed",
+			"operation", call.operation,
+			"project_token", call.projectToken,
+			"run_token", call.runToken,
+			"duration", time.Since(start),
+			"error", err,
+		)
+		return nil, err
+	}
+
+	parsehub.logDebug(
+		"Request finished",
+		"operation", call.operation,
+		"project_token", call.projectToken,
+		"run_token", call.runToken,
+		"duration", time.Since(start),
+	)
+
+	return body, nil
+}
+
+// Executes api call with retries according to client retry policy.
+// Non-idempotent calls are retried only if policy allows it.
+func (parsehub *ParseHub) executeWithRetry(ctx context.Context, call apiCall) ([]byte, error) {
+	policy := parsehub.retryPolicy
+
+	for attempt := 1; ; attempt++ {
+		if err := parsehub.waitRateLimit(ctx, call); err != nil {
+			return nil, err
+		}
+
+		request, err := parsehub.newRequest(ctx, call.method, call.path, call.values)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := parsehub.do(call, attempt, request)
+
+		canRetry := attempt < policy.MaxAttempts &&
+			(call.idempotent || policy.RetryNonIdempotent) &&
+			ctx.Err() == nil &&
+			policy.retryable(resp, err)
+
+		if !canRetry {
+			if err != nil {
+				return nil, err
+			}
+			return readResponse(call, resp)
+		}
+
+		delay := policy.backoff(attempt, resp)
+
+		if err != nil {
+			parsehub.logWarn("Attempt failed, retry", "operation", call.operation, "project_token", call.projectToken, "run_token", call.runToken, "attempt", attempt, "error", err, "delay", delay)
+		} else {
+			parsehub.logWarn("Attempt failed, retry", "operation", call.operation, "project_token", call.projectToken, "run_token", call.runToken, "attempt", attempt, "status", resp.StatusCode, "delay", delay)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Reads response body and checks status code
+func readResponse(call apiCall, resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if statusErr := checkStatusCode(call.method, call.path, resp.StatusCode, body); statusErr != nil {
+		return nil, statusErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// Same as decodeResponse but secrets are masked in returned error
+func (parsehub *ParseHub) decodeResponse(endpoint string, body []byte, target interface{}) error {
+	if err := decodeResponse(endpoint, body, target); err != nil {
+		return parsehub.redactError(err)
+	}
+
+	return nil
+}
+
+// Decodes JSON response of endpoint into target
+func decodeResponse(endpoint string, body []byte, target interface{}) error {
+	if err := json.Unmarshal(body, target); err != nil {
+		return &DecodeError{
+			Endpoint: endpoint,
+			Body:     string(body),
+			Err:      err,
+		}
+	}
+
+	return nil
+}
@@ -1,5 +1,7 @@
 package parsehub
 
+import "time"
+
 // ParseHub Projects
 type ProjectsResponse struct {
 	Projects []*ProjectResponse `json:"projects"`
@@ -15,7 +17,7 @@ type ProjectResponse struct {
 
 	// The JSON-stringified representation of all the instructions for running this project. 
 	// This representation is not yet documented, but will eventually allow developers to create 
-	// plugins for ParseHub.
+	// plugins for ParseHub. Use Templates() for decoded templates.
 	TemplatesJSON string `json:"templates_json"`
 
 	// The name of the template with which ParseHub should start executing the project.
@@ -24,8 +26,8 @@ type ProjectResponse struct {
 	// The default URL at which ParseHub should start running the project.
 	Main_site     string `json:"main_site"`
 
-	// An object containing several advanced options for the project.
-	OptionsJSON   string `json:"option_json"`
+	// An object containing several advanced options for the project. Use Options() for decoded options.
+	OptionsJSON   string `json:"options_json"`
 
 	// The run object of the most recently started run (orderd by start_time) for the project.
 	LastRun       *RunResponse `json:"last_run"`
@@ -45,19 +47,19 @@ type RunResponse struct {
 	RunToken      string `json:"run_token"`
 
 	// The status of the run. It can be one of initialized, queued, running, cancelled, complete, or error.
-	Status        string `json:"status"`
+	Status        RunStatus `json:"status"`
 
 	// Whether the data for this run is ready to download. If the status is complete, this will always be truthy. 
 	// If the status is cancelled or error, then this may be truthy or falsy, depending on whether any 
 	// data is available.
-	DataReady     uint8 `json:"data_ready"`
+	DataReady     bool `json:"data_ready"`
 
 	// The time that this run was started at, in UTC +0000.
-	StartTime     string `json:"start_time"`
+	StartTime     time.Time `json:"start_time"`
 
-	// The time that this run was stopped. This field will be null if the run is either initialized or running. 
+	// The time that this run was stopped. This field will be nil if the run is either initialized or running. 
 	// Time is in UTC +0000.
-	EndTime       string `json:"end_time"`
+	EndTime       *time.Time `json:"end_time"`
 
 	// The number of pages that have been traversed by this run so far.
 	Pages         int64 `json:"pages"`
@@ -72,6 +74,19 @@ type RunResponse struct {
 	StartTemplate string `json:"start_template"`
 
 	// The starting value of the global scope for this run.
-	StartValue    string `json:"start_value"`
+	StartValue    map[string]interface{} `json:"start_value"`
 }
 
+
+// Returns run duration. Duration of unfinished run grows until run is finished.
+func (response RunResponse) Duration() time.Duration {
+	if response.StartTime.IsZero() {
+		return 0
+	}
+
+	if response.EndTime != nil {
+		return response.EndTime.Sub(response.StartTime)
+	}
+
+	return time.Since(response.StartTime)
+}
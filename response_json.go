@@ -0,0 +1,244 @@
+package parsehub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Time format of ParseHub API, UTC without zone
+const timeFormat = "2006-01-02T15:04:05"
+
+// Accepted time formats of ParseHub API and webhooks
+var timeFormats = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+func (response *RunResponse) UnmarshalJSON(body []byte) error {
+	type plain RunResponse
+
+	// fields in ParseHub wire format shadow fields of run data
+	wire := struct {
+		*plain
+		DataReady  json.RawMessage `json:"data_ready"`
+		StartTime  *string         `json:"start_time"`
+		EndTime    *string         `json:"end_time"`
+		StartValue json.RawMessage `json:"start_value"`
+	}{plain: (*plain)(response)}
+
+	if err := json.Unmarshal(body, &wire); err != nil {
+		return err
+	}
+
+	var err error
+
+	if response.DataReady, err = parseDataReady(string(bytes.Trim(wire.DataReady, `"`))); err != nil {
+		return err
+	}
+
+	startTime, err := parseTime(wire.StartTime)
+	if err != nil {
+		return fmt.Errorf("start_time: %s", err)
+	}
+	response.StartTime = time.Time{}
+	if startTime != nil {
+		response.StartTime = *startTime
+	}
+
+	if response.EndTime, err = parseTime(wire.EndTime); err != nil {
+		return fmt.Errorf("end_time: %s", err)
+	}
+
+	response.StartValue = nil
+	if len(wire.StartValue) > 0 && wire.StartValue[0] == '"' {
+		// start value is JSON-stringified object
+		var startValue string
+		if err := json.Unmarshal(wire.StartValue, &startValue); err != nil {
+			return fmt.Errorf("start_value: %s", err)
+		}
+		if response.StartValue, err = parseStartValue(startValue); err != nil {
+			return err
+		}
+	} else if len(wire.StartValue) > 0 {
+		if err := json.Unmarshal(wire.StartValue, &response.StartValue); err != nil {
+			return fmt.Errorf("start_value: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (response RunResponse) MarshalJSON() ([]byte, error) {
+	wire, err := response.wire()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(wire)
+}
+
+// Run data without JSON methods
+type plainRunResponse RunResponse
+
+// Run data in ParseHub wire format
+type runResponseWire struct {
+	plainRunResponse
+	DataReady  uint8   `json:"data_ready"`
+	StartTime  *string `json:"start_time"`
+	EndTime    *string `json:"end_time"`
+	StartValue string  `json:"start_value"`
+}
+
+// Returns run data in ParseHub wire format
+func (response RunResponse) wire() (runResponseWire, error) {
+	wire := runResponseWire{plainRunResponse: plainRunResponse(response)}
+
+	if response.DataReady {
+		wire.DataReady = 1
+	}
+
+	if !response.StartTime.IsZero() {
+		startTime := response.StartTime.UTC().Format(timeFormat)
+		wire.StartTime = &startTime
+	}
+
+	if response.EndTime != nil {
+		endTime := response.EndTime.UTC().Format(timeFormat)
+		wire.EndTime = &endTime
+	}
+
+	if response.StartValue != nil {
+		startValue, err := json.Marshal(response.StartValue)
+		if err != nil {
+			return wire, err
+		}
+		wire.StartValue = string(startValue)
+	}
+
+	return wire, nil
+}
+
+// Snapshot fields next to run data in wire format
+type runSnapshotWire struct {
+	runResponseWire
+	Version   uint64
+	FetchedAt time.Time
+}
+
+// Keeps snapshot fields in JSON of embedded run data
+func (snapshot RunSnapshot) MarshalJSON() ([]byte, error) {
+	wire, err := snapshot.RunResponse.wire()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(runSnapshotWire{
+		runResponseWire: wire,
+		Version:         snapshot.Version,
+		FetchedAt:       snapshot.FetchedAt,
+	})
+}
+
+// Decodes run data and snapshot fields, promoted method of run data would drop them
+func (snapshot *RunSnapshot) UnmarshalJSON(body []byte) error {
+	if err := snapshot.RunResponse.UnmarshalJSON(body); err != nil {
+		return err
+	}
+
+	meta := struct {
+		Version   uint64
+		FetchedAt time.Time
+	}{}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return err
+	}
+
+	snapshot.Version = meta.Version
+	snapshot.FetchedAt = meta.FetchedAt
+
+	return nil
+}
+
+// Returns copy of run data that does not share time and start value with original
+func (response RunResponse) clone() RunResponse {
+	if response.EndTime != nil {
+		endTime := *response.EndTime
+		response.EndTime = &endTime
+	}
+
+	if response.StartValue != nil {
+		response.StartValue = copyValue(response.StartValue).(map[string]interface{})
+	}
+
+	return response
+}
+
+// Returns deep copy of decoded JSON value
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+
+	return value
+}
+
+// Parses nullable time of ParseHub API in UTC
+func parseTime(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+
+	for _, format := range timeFormats {
+		if parsed, err := time.ParseInLocation(format, *value, time.UTC); err == nil {
+			return &parsed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown time format %q", *value)
+}
+
+// Parses data_ready flag sent as number or boolean
+func parseDataReady(value string) (bool, error) {
+	switch value {
+	case "", "null", "0", "false", "False":
+		return false, nil
+	case "true", "True":
+		return true, nil
+	}
+
+	number, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return false, fmt.Errorf("data_ready: %s", err)
+	}
+
+	return number != 0, nil
+}
+
+// Parses JSON-stringified start value
+func parseStartValue(value string) (map[string]interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	startValue := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value), &startValue); err != nil {
+		return nil, fmt.Errorf("start_value: %s", err)
+	}
+
+	return startValue, nil
+}
@@ -0,0 +1,151 @@
+package parsehub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRunResponseUnmarshalJSON(t *testing.T) {
+	body := `{
+		"project_token": "project",
+		"run_token": "run",
+		"status": "complete",
+		"data_ready": 1,
+		"start_time": "2019-06-24T09:30:00",
+		"end_time": "2019-06-24T10:00:00.123456",
+		"pages": 7,
+		"start_value": "{\"query\": \"San Francisco\", \"pages\": [1, 2]}"
+	}`
+
+	response := &RunResponse{}
+	if err := json.Unmarshal([]byte(body), response); err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Status.IsTerminal() || !response.Status.IsSuccess() || !response.DataReady {
+		t.Fatalf("unexpected status %s, data ready %t", response.Status, response.DataReady)
+	}
+
+	if !response.StartTime.Equal(time.Date(2019, 6, 24, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected start time %s", response.StartTime)
+	}
+
+	if response.Duration() != 30*time.Minute+123456*time.Microsecond {
+		t.Fatalf("unexpected duration %s", response.Duration())
+	}
+
+	if response.StartValue["query"] != "San Francisco" {
+		t.Fatalf("unexpected start value %v", response.StartValue)
+	}
+
+	// round trip keeps ParseHub wire format
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &RunResponse{}
+	if err := json.Unmarshal(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !decoded.DataReady || !decoded.StartTime.Equal(response.StartTime) || decoded.EndTime == nil || decoded.StartValue["query"] != "San Francisco" {
+		t.Fatalf("unexpected round trip %s", encoded)
+	}
+}
+
+func TestRunResponseUnmarshalNullableFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		dataReady bool
+	}{
+		{"null", `{"run_token":"run","status":"running","data_ready":null,"start_time":null,"end_time":null,"start_value":null}`, false},
+		{"empty", `{"run_token":"run","status":"running","data_ready":0,"start_time":"","end_time":"","start_value":""}`, false},
+		{"missing", `{"run_token":"run","status":"running"}`, false},
+		{"bool data ready", `{"run_token":"run","status":"running","data_ready":true,"start_value":{"query":"x"}}`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := &RunResponse{}
+			if err := json.Unmarshal([]byte(test.body), response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.DataReady != test.dataReady || response.EndTime != nil || !response.StartTime.IsZero() || response.Duration() != 0 {
+				t.Fatalf("unexpected response %+v", response)
+			}
+
+			if response.Status.IsTerminal() {
+				t.Fatal("running status is terminal")
+			}
+		})
+	}
+
+	if err := json.Unmarshal([]byte(`{"start_time":"yesterday"}`), &RunResponse{}); err == nil {
+		t.Fatal("expected error for malformed time")
+	}
+}
+
+func TestRunSnapshotIsDeepCopy(t *testing.T) {
+	run := NewRun(NewParseHub("test-api-key"), "run")
+	run.setResponse(&RunResponse{
+		RunToken:   "run",
+		Status:     RunStatusComplete,
+		EndTime:    &testEndTime,
+		StartValue: map[string]interface{}{"query": "x"},
+	})
+
+	snapshot := run.GetResponse()
+	snapshot.StartValue["query"] = "y"
+	*snapshot.EndTime = time.Time{}
+
+	if fresh := run.GetResponse(); fresh.StartValue["query"] != "x" || fresh.EndTime.IsZero() {
+		t.Fatalf("snapshot shares data with run, got %+v", fresh)
+	}
+}
+
+func TestRunSnapshotJSONRoundTrip(t *testing.T) {
+	snapshot := RunSnapshot{
+		RunResponse: RunResponse{
+			RunToken:   "run",
+			Status:     RunStatusComplete,
+			DataReady:  true,
+			StartTime:  time.Date(2019, 6, 24, 9, 30, 0, 0, time.UTC),
+			EndTime:    &testEndTime,
+			StartValue: map[string]interface{}{"query": "x"},
+		},
+		Version:   3,
+		FetchedAt: time.Date(2019, 6, 24, 10, 0, 0, 0, time.UTC),
+	}
+
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wire := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &wire); err != nil {
+		t.Fatal(err)
+	}
+
+	if wire["data_ready"] != float64(1) || wire["start_time"] != "2019-06-24T09:30:00" || wire["Version"] != float64(3) {
+		t.Fatalf("snapshot is not encoded in wire format: %s", encoded)
+	}
+
+	decoded := RunSnapshot{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Version != snapshot.Version || !decoded.FetchedAt.Equal(snapshot.FetchedAt) {
+		t.Fatalf("snapshot fields are lost, got %+v", decoded)
+	}
+
+	if decoded.RunToken != "run" || !decoded.DataReady || !decoded.StartTime.Equal(snapshot.StartTime) ||
+		!decoded.EndTime.Equal(testEndTime) || decoded.StartValue["query"] != "x" {
+		t.Fatalf("run data is lost, got %+v", decoded.RunResponse)
+	}
+}
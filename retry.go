@@ -0,0 +1,118 @@
+package parsehub
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry policy for requests to ParseHub
+type RetryPolicy struct {
+	// Maximum number of attempts including the first one. Values less than 2 disable retries.
+	MaxAttempts int
+
+	// Backoff before the second attempt. Doubles with every next attempt.
+	MinBackoff time.Duration
+
+	// Upper limit for backoff and Retry-After delays.
+	MaxBackoff time.Duration
+
+	// Retry non-idempotent calls like Project.Run.
+	// Enable only if duplicated runs are acceptable.
+	RetryNonIdempotent bool
+
+	// Decides whether attempt should be retried. Defaults to DefaultRetryable.
+	Retryable func(resp *http.Response, err error) bool
+}
+
+// Default retry policy of ParseHub adapter
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+}
+
+// Retries network errors, 429 Too Many Requests and 5xx responses
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// Set retry policy for all requests to ParseHub
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.retryPolicy = policy
+	}
+}
+
+// Checks whether attempt should be retried
+func (policy RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if policy.Retryable != nil {
+		return policy.Retryable(resp, err)
+	}
+
+	return DefaultRetryable(resp, err)
+}
+
+// Returns delay before next attempt.
+// Retry-After header has priority over jittered exponential backoff.
+func (policy RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+				delay = policy.MaxBackoff
+			}
+			return delay
+		}
+	}
+
+	return jitteredBackoff(attempt, policy.MinBackoff, policy.MaxBackoff)
+}
+
+// Returns exponential backoff for attempt with equal jitter
+func jitteredBackoff(attempt int, minBackoff time.Duration, maxBackoff time.Duration) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempt && (maxBackoff <= 0 || delay < maxBackoff); i++ {
+		delay *= 2
+	}
+
+	if maxBackoff > 0 && delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	// equal jitter: keep half of delay and randomize the rest
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// Parses Retry-After header in seconds or HTTP date format
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,214 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test retry policy without noticeable delays
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  10 * time.Millisecond,
+}
+
+// Server responding with statuses in order, the last status is repeated.
+// Successful responses contain finished run.
+func newStatusServer(requests *int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(requests, 1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+
+		if statuses[i] != http.StatusOK {
+			http.Error(w, http.StatusText(statuses[i]), statuses[i])
+			return
+		}
+
+		writeJSON(w, RunResponse{RunToken: "run", Status: RunStatusComplete})
+	}))
+}
+
+func TestRetryServerError(t *testing.T) {
+	var requests int32
+	server := newStatusServer(&requests, http.StatusServiceUnavailable, http.StatusOK)
+	defer server.Close()
+
+	parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(testRetryPolicy))
+
+	run, err := parsehub.GetRun("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.GetResponse().Status != RunStatusComplete || atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("expected successful second attempt, got %d requests", requests)
+	}
+}
+
+func TestRetryStopsAfterMaxAttempts(t *testing.T) {
+	var requests int32
+	server := newStatusServer(&requests, http.StatusBadGateway)
+	defer server.Close()
+
+	parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(testRetryPolicy))
+
+	if _, err := parsehub.GetRun("run"); !errors.Is(err, ErrServer) {
+		t.Fatalf("expected ErrServer, got %v", err)
+	}
+
+	if requests != 3 {
+		t.Fatalf("expected 3 attempts, got %d", requests)
+	}
+}
+
+func TestRetryBackoffHonoursRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: time.Second, MaxBackoff: time.Minute}
+
+	tests := []struct {
+		name       string
+		retryAfter string
+		expected   time.Duration
+	}{
+		{"seconds", "5", 5 * time.Second},
+		{"capped", "3600", time.Minute},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Retry-After": {test.retryAfter}}}
+			if delay := policy.backoff(1, resp); delay != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, delay)
+			}
+		})
+	}
+
+	// date in future is capped too
+	resp := &http.Response{Header: http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}}
+	if delay := policy.backoff(1, resp); delay != time.Minute {
+		t.Fatalf("expected capped date delay, got %s", delay)
+	}
+
+	// malformed header falls back to jittered exponential backoff
+	resp = &http.Response{Header: http.Header{"Retry-After": {"soon"}}}
+	if delay := policy.backoff(3, resp); delay < 2*time.Second || delay > 4*time.Second {
+		t.Fatalf("expected backoff between 2s and 4s, got %s", delay)
+	}
+}
+
+func TestRetryWaitsRetryAfter(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+
+		writeJSON(w, RunResponse{RunToken: "run", Status: RunStatusRunning})
+	}))
+	defer server.Close()
+
+	// Retry-After of 1s is capped by MaxBackoff
+	parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 2,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  50 * time.Millisecond,
+	}))
+
+	start := time.Now()
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("expected capped Retry-After delay, got %s", elapsed)
+	}
+}
+
+func TestRetryNonIdempotentCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    bool
+		expected int32
+	}{
+		{"not retried by default", false, 1},
+		{"retried when allowed", true, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := newStatusServer(&requests, http.StatusServiceUnavailable)
+			defer server.Close()
+
+			policy := testRetryPolicy
+			policy.RetryNonIdempotent = test.retry
+
+			parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(policy))
+
+			if _, err := NewProject(parsehub, "project").Run(ProjectRunParams{}, nil); !errors.Is(err, ErrServer) {
+				t.Fatalf("expected ErrServer, got %v", err)
+			}
+
+			if requests != test.expected {
+				t.Fatalf("expected %d attempts, got %d", test.expected, requests)
+			}
+		})
+	}
+}
+
+func TestRetryCustomRetryable(t *testing.T) {
+	var requests int32
+	server := newStatusServer(&requests, http.StatusNotFound, http.StatusServiceUnavailable, http.StatusOK)
+	defer server.Close()
+
+	var checked int32
+	policy := testRetryPolicy
+	policy.Retryable = func(resp *http.Response, err error) bool {
+		atomic.AddInt32(&checked, 1)
+		return err == nil && resp.StatusCode == http.StatusNotFound
+	}
+
+	parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(policy))
+
+	// 404 is retried, 503 is not
+	if _, err := parsehub.GetRun("run"); !errors.Is(err, ErrServer) {
+		t.Fatalf("expected ErrServer, got %v", err)
+	}
+
+	if requests != 2 || checked != 2 {
+		t.Fatalf("expected 2 attempts checked by custom retryable, got %d attempts, %d checks", requests, checked)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	var requests int32
+	server := newStatusServer(&requests, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Hour,
+		MaxBackoff:  time.Hour,
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := parsehub.GetRunContext(ctx, "run"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second || requests != 1 {
+		t.Fatalf("backoff is not cancelled, got %d attempts after %s", requests, elapsed)
+	}
+}
@@ -1,11 +1,9 @@
 package parsehub
 
 import (
-	"encoding/json"
-	"github.com/defval/parsehub/internal"
-	"io/ioutil"
+	"context"
 	"net/http"
-	"net/url"
+	"sync"
 	"time"
 )
 
@@ -14,187 +12,433 @@ type HandleRunFunc func(run *Run) error
 // ParseHub Run Wrapper
 type Run struct {
 	parsehub *ParseHub
+	token    string
 
-	token      string
-	response   *RunResponse
-	handleFunc HandleRunFunc
-
+	mu       sync.RWMutex
+	snapshot *RunSnapshot
+	handlers RunHandlers
+	guards   RunGuards
 	watching bool
+
+	// Error of ParseHub API which stopped last watch
+	watchErr error
+
+	// Time when run was started or first watched, kept over watches
+	startedAt time.Time
+
+	// Name of registered handlers and metadata of run persisted in watch store
+	handlerName string
+	metadata    map[string]string
+
+	// Context of running handler call
+	ctx context.Context
+
+	// Closed when run data with terminal status is received
+	terminated       chan struct{}
+	terminatedClosed bool
+
+	subscriptions []*runSubscription
 }
 
-// Creates new ParseHub run wrapper
+// Returns ParseHub run wrapper registered in client or creates and registers new one
 func NewRun(parsehub *ParseHub, token string) *Run {
-	run := parsehub.runRegistry[token]
+	return parsehub.runRegistry.getOrCreate(token, func() *Run {
+		return newRun(parsehub, token)
+	})
+}
 
-	if run == nil {
-		run = &Run{
-			parsehub: parsehub,
-			token:    token,
-		}
+// Creates run wrapper which is not registered in client
+func newRun(parsehub *ParseHub, token string) *Run {
+	return &Run{
+		parsehub:   parsehub,
+		token:      token,
+		terminated: make(chan struct{}),
+	}
+}
+
+// Returns metadata of run started with named handler
+func (r *Run) Metadata() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metadata := make(map[string]string, len(r.metadata))
+	for key, value := range r.metadata {
+		metadata[key] = value
+	}
+
+	return metadata
+}
+
+func (r *Run) setNamedHandler(name string, metadata map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlerName = name
+	r.metadata = metadata
+}
+
+func (r *Run) getHandlerName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.handlerName
+}
+
+// Returns context of handler call: watch context for watched runs and request context
+// for runs of webhooks. Handlers should stop work when it is done. Defaults to context.Background().
+func (r *Run) Context() context.Context {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.ctx == nil {
+		return context.Background()
 	}
 
-	return run
+	return r.ctx
 }
 
-// Set run handler
+func (r *Run) setContext(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ctx = ctx
+}
+
+// Set run handler called for every finished run
 func (r *Run) SetHandler(handleFunc HandleRunFunc) {
-	r.handleFunc = handleFunc
+	r.SetHandlers(handlersOf(handleFunc))
 }
 
-// Get run data
-func (r *Run) GetResponse() *RunResponse {
-	return r.response
+// Set run handlers called by outcome of finished run
+func (r *Run) SetHandlers(handlers RunHandlers) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers = handlers
 }
 
-// This load the data that was extracted by a run.
-func (r *Run) LoadData(target interface{}) error {
-	debugf("Run.LoadData: Load data for run %v", r.token)
+// Returns run handlers
+func (r *Run) getHandlers() RunHandlers {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	requestUrl, _ := url.Parse(BaseUrl + "v2/runs/" + r.token + "/data")
+	return r.handlers
+}
 
-	values := url.Values{}
-	values.Add("api_key", r.parsehub.apiKey)
+// Set limits of watched run. Run exceeding limits is cancelled
+// and handled by GuardTripped handler.
+func (r *Run) SetGuards(guards RunGuards) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	requestUrl.RawQuery = values.Encode()
+	r.guards = guards
+}
 
-	if resp, err := http.Get(requestUrl.String()); err != nil {
-		warningf("Run.LoadData: ParseHub HTTP request problem: %s", err.Error())
-		return err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
+// Returns run guards
+func (r *Run) getGuards() RunGuards {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.guards
+}
 
-		body, _ := ioutil.ReadAll(resp.Body)
-		debugf("Run.LoadData: Body string: %s", body)
+// Get snapshot of run data. Returns nil if run data was not loaded yet.
+// Compare Version of snapshots to find out which one is fresher.
+func (r *Run) GetResponse() *RunSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-		if err := json.Unmarshal(body, target); err != nil {
-			warningf("Run.LoadData: Unmarshal error with body %s", body)
-			return err
-		}
+	return r.snapshot.clone()
+}
+
+// Replaces run data with new snapshot.
+// Responses may come out of order, finished run is never updated with earlier status.
+func (r *Run) setResponse(response *RunResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.snapshot != nil && r.snapshot.Status.IsTerminal() && !response.Status.IsTerminal() {
+		return
+	}
+
+	snapshot := &RunSnapshot{
+		RunResponse: *response,
+		Version:     1,
+		FetchedAt:   time.Now(),
+	}
+
+	if r.snapshot != nil {
+		snapshot.Version = r.snapshot.Version + 1
+	}
+
+	if response.Status.IsTerminal() && !r.terminatedClosed {
+		r.terminatedClosed = true
+		close(r.terminated)
+	}
+
+	r.publish(r.snapshot, snapshot)
+	r.snapshot = snapshot
+}
+
+// Returns token of run project if run data is loaded
+func (r *Run) projectToken() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.snapshot == nil {
+		return ""
+	}
+
+	return r.snapshot.ProjectToken
+}
+
+// Marks run as watched. Returns false if run is already watched.
+func (r *Run) startWatching() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.watching {
+		return false
+	}
+
+	r.watching = true
+	r.watchErr = nil
+	if r.startedAt.IsZero() {
+		r.startedAt = time.Now()
+	}
+	return true
+}
+
+// Set time when run was started
+func (r *Run) setStartedAt(startedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-		debugf("Run.LoadData: Target: %+v", target)
+	r.startedAt = startedAt
+}
+
+// Returns duration of run since its StartTime reported by ParseHub.
+// Falls back to time when run was started or first watched by client.
+func (r *Run) elapsed(snapshot *RunSnapshot) time.Duration {
+	if !snapshot.StartTime.IsZero() {
+		return time.Since(snapshot.StartTime)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return time.Since(r.startedAt)
+}
+
+// Marks run as not watched
+func (r *Run) stopWatching() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watching = false
+}
+
+// Marks run as not watched because of permanent error
+func (r *Run) failWatching(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watching = false
+	r.watchErr = err
+}
+
+// Returns error which stopped last watch
+func (r *Run) watchError() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.watchErr
+}
+
+// This load the data that was extracted by a run.
+func (r *Run) LoadData(target interface{}) error {
+	return r.LoadDataContext(context.Background(), target)
+}
+
+// Same as LoadData but request is cancelled with context
+func (r *Run) LoadDataContext(ctx context.Context, target interface{}) error {
+	r.parsehub.logDebug("Load data", "operation", OperationLoadRunData, "run_token", r.token)
+
+	body, err := r.parsehub.execute(ctx, apiCall{
+		operation:    OperationLoadRunData,
+		method:       http.MethodGet,
+		path:         "v2/runs/" + r.token + "/data",
+		idempotent:   true,
+		projectToken: r.projectToken(),
+		runToken:     r.token,
+	})
+	if err != nil {
+		r.parsehub.logWarn("ParseHub request problem", "operation", OperationLoadRunData, "run_token", r.token, "error", err)
 		return err
-	} else {
-		warningf("Run.LoadData: ParseHub HTTP response problem: %s", err.Error())
+	}
+
+	r.parsehub.logDebug("Response string", "operation", OperationLoadRunData, "run_token", r.token, "body", body)
+
+	if err := r.parsehub.decodeResponse("v2/runs/"+r.token+"/data", body, target); err != nil {
+		r.parsehub.logWarn("Unmarshal error", "operation", OperationLoadRunData, "run_token", r.token, "body", body, "error", err)
 		return err
 	}
+
+	r.parsehub.logDebug("Loaded data", "operation", OperationLoadRunData, "run_token", r.token, "target", target)
+	return nil
 }
 
 // This cancels a run and changes its status to cancelled.
 // Any data that was extracted so far will be available.
 func (r *Run) Cancel() error {
-	debugf("Run.Cancel: Cancel run %v", r.token)
-	requestUrl, _ := url.Parse(BaseUrl + "v2/runs/" + r.token + "/cancel")
-
-	values := url.Values{}
-	values.Add("api_key", r.parsehub.apiKey)
-	requestUrl.RawQuery = values.Encode()
+	return r.CancelContext(context.Background())
+}
 
-	if resp, err := http.PostForm(requestUrl.String(), values); err != nil {
-		warningf("Run.Cancel: ParseHub HTTP request problem: %s", err.Error())
+// Same as Cancel but request is cancelled with context
+func (r *Run) CancelContext(ctx context.Context) error {
+	r.parsehub.logDebug("Cancel run", "operation", OperationCancelRun, "run_token", r.token)
+	body, err := r.parsehub.execute(ctx, apiCall{
+		operation:    OperationCancelRun,
+		method:       http.MethodPost,
+		path:         "v2/runs/" + r.token + "/cancel",
+		idempotent:   true, // cancelling cancelled run changes nothing
+		mutating:     true,
+		projectToken: r.projectToken(),
+		runToken:     r.token,
+	})
+	if err != nil {
+		r.parsehub.logWarn("ParseHub request problem", "operation", OperationCancelRun, "run_token", r.token, "error", err)
 		return err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
-
-		body, _ := ioutil.ReadAll(resp.Body)
-		debugf("Run.Cancel: Cancel run response string: %s", body)
-
-		runResponse := &RunResponse{}
-		if err := json.Unmarshal(body, runResponse); err != nil {
-			warningf("Run.Cancel: Unmarshal error with body %s", body)
-			return err
-		}
-
-		debugf("Run.Cancel: Cancel run response: %+v", runResponse)
+	}
 
-		r.response = runResponse // update response
+	r.parsehub.logDebug("Response string", "operation", OperationCancelRun, "run_token", r.token, "body", body)
 
-		return nil
-	} else {
-		warningf("Run.Cancel: ParseHub HTTP response problem: %s", err.Error())
+	runResponse := &RunResponse{}
+	if err := r.parsehub.decodeResponse("v2/runs/"+r.token+"/cancel", body, runResponse); err != nil {
+		r.parsehub.logWarn("Unmarshal error", "operation", OperationCancelRun, "run_token", r.token, "body", body, "error", err)
 		return err
 	}
 
+	r.parsehub.logDebug("Cancel run response", "operation", OperationCancelRun, "run_token", r.token, "status", runResponse.Status)
+
+	r.setResponse(runResponse)
+
+	return nil
 }
 
 // Refresh run data
 func (r *Run) Refresh() error {
-	_, err := r.parsehub.GetRun(r.token)
+	return r.RefreshContext(context.Background())
+}
+
+// Same as Refresh but request is cancelled with context
+func (r *Run) RefreshContext(ctx context.Context) error {
+	_, err := r.parsehub.GetRunContext(ctx, r.token)
 	return err
 }
 
 // This cancels a run if running, and deletes the run and its data.
 func (r *Run) Delete() error {
-	debugf("Run.Delete: Delete run %v", r.token)
-	requestUrl, _ := url.Parse(BaseUrl + "v2/runs/" + r.token)
+	return r.DeleteContext(context.Background())
+}
+
+// Same as Delete but request is cancelled with context
+func (r *Run) DeleteContext(ctx context.Context) error {
+	r.parsehub.logDebug("Delete run", "operation", OperationDeleteRun, "run_token", r.token)
+	body, err := r.parsehub.execute(ctx, apiCall{
+		operation:    OperationDeleteRun,
+		method:       http.MethodDelete,
+		path:         "v2/runs/" + r.token,
+		idempotent:   true,
+		mutating:     true,
+		projectToken: r.projectToken(),
+		runToken:     r.token,
+	})
+	if err != nil {
+		r.parsehub.logWarn("ParseHub request problem", "operation", OperationDeleteRun, "run_token", r.token, "error", err)
+		return err
+	}
 
-	values := url.Values{}
-	values.Add("api_key", r.parsehub.apiKey)
-	requestUrl.RawQuery = values.Encode()
+	r.parsehub.logDebug("Response string", "operation", OperationDeleteRun, "run_token", r.token, "body", body)
 
-	request, _ := http.NewRequest(http.MethodDelete, requestUrl.String(), nil)
+	runResponse := &RunResponse{}
 
-	if resp, err := http.DefaultClient.Do(request); err != nil {
-		warningf("Run.Delete: ParseHub HTTP request problem: %s", err.Error())
+	if err := r.parsehub.decodeResponse("v2/runs/"+r.token, body, runResponse); err != nil {
+		r.parsehub.logWarn("Unmarshal error", "operation", OperationDeleteRun, "run_token", r.token, "body", body, "error", err)
 		return err
-	} else if success, err := internal.CheckHTTPStatusCode(resp.StatusCode); success {
-		defer resp.Body.Close()
+	}
+	r.parsehub.logDebug("Delete run response", "operation", OperationDeleteRun, "run_token", r.token, "status", runResponse.Status)
+
+	r.setResponse(runResponse)
 
-		body, _ := ioutil.ReadAll(resp.Body)
-		debugf("Run.Delete: Delete run response string: %s", body)
+	r.parsehub.runRegistry.delete(r)
+	return nil
+}
 
-		runResponse := &RunResponse{}
+// Blocks until run status is complete, error or cancelled and returns final run data.
+// Run is polled by client scheduler if it is not watched yet.
+// Returns *RunError for failed and cancelled runs, context error when context is done,
+// ErrClosed when client is closed and *APIError when watching is stopped by permanent
+// error of ParseHub API, e.g. ErrUnauthorized.
+func (r *Run) Wait(ctx context.Context) (*RunResponse, error) {
+	for {
+		select {
+		case <-r.terminated:
+			return r.result()
+		default:
+		}
 
-		if err := json.Unmarshal(body, runResponse); err != nil {
-			warningf("Run.Delete: Unmarshal error with body %s", body)
-			return err
+		if r.parsehub.watcher.isClosed() {
+			return nil, ErrClosed
 		}
-		debugf("Run.Delete: Delete run response: %v", runResponse)
 
-		r.response = runResponse
+		// watch again if previous watch was stopped by its context
+		r.parsehub.watcher.watch(ctx, r)
 
-		internal.Lock.Lock()
-		delete(r.parsehub.runRegistry, r.token)
-		internal.Lock.Unlock()
-		return nil
-	} else {
-		warningf("Run.Delete: ParseHub HTTP response problem: %s", err.Error())
-		return err
+		select {
+		case <-r.terminated:
+			return r.result()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.parsehub.watcher.closing:
+		case <-time.After(r.parsehub.pollInterval):
+		}
+
+		if err := r.watchError(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Returns data of terminated run and error if run is not successful
+func (r *Run) result() (*RunResponse, error) {
+	response := r.GetResponse().RunResponse
+
+	if !response.Status.IsSuccess() {
+		return &response, &RunError{RunToken: r.token, Status: response.Status}
 	}
+
+	return &response, nil
 }
 
-// Watch for complete run and handle if handler exist
-// Use SetHandler() for handle run data
+// Watch for finished run and handle if handler exist
+// Use SetHandler() or SetHandlers() for handle run data
 func (r *Run) WatchAndHandle() {
+	r.WatchAndHandleContext(context.Background())
+}
+
+// Same as WatchAndHandle but watching stops when context is done.
+// Handler is not called if context is done before run is finished.
+// Run is polled by client scheduler, method blocks until watching and handling are finished.
+func (r *Run) WatchAndHandleContext(ctx context.Context) {
+	watch := r.parsehub.watcher.watch(ctx, r)
+
 	// No double watches
-	if r.watching {
-		warningf("Run.WatchAndHandle: Watching double run with token %s", r.token) // its not a problem
+	if watch == nil {
+		r.parsehub.logWarn("Watching double run or closed client", "operation", "Run.WatchAndHandle", "run_token", r.token) // its not a problem
 		return
 	}
 
-	debugf("Run.WatchAndHandle: Start watching run with token %s", r.token)
-	r.watching = true
-
-	for {
-		time.Sleep(10 * time.Second) // todo: delete hardcoded time
-
-		debugf("Run.WatchAndHandle: Watch iteration run with token %s", r.token)
-		r.parsehub.GetRun(r.token)
-
-		// todo: add conditions for stop watching
-		if r.response.EndTime != "" {
-			r.watching = false
-
-			debugf("Run.WatchAndHandle: Watch finished. Handle run with token %s", r.token)
-			if err := r.handleFunc(r); err != nil {
-				warningf("Run.WatchAndHandle: Handle run with token %s error: %s", r.token, err.Error())
-				return
-			} else {
-				internal.Lock.Lock()
-				delete(r.parsehub.runRegistry, r.token)
-				internal.Lock.Unlock()
-			}
-
-			return // stop watching
-		}
-	}
+	r.parsehub.logDebug("Start watching run", "operation", "Run.WatchAndHandle", "run_token", r.token)
+	<-watch.done
 }
@@ -0,0 +1,27 @@
+package parsehub
+
+// Status of run
+type RunStatus string
+
+const (
+	RunStatusInitialized RunStatus = "initialized"
+	RunStatusQueued      RunStatus = "queued"
+	RunStatusRunning     RunStatus = "running"
+	RunStatusCancelled   RunStatus = "cancelled"
+	RunStatusComplete    RunStatus = "complete"
+	RunStatusError       RunStatus = "error"
+)
+
+// Checks whether run with status is finished
+func (status RunStatus) IsTerminal() bool {
+	return status == RunStatusComplete || status == RunStatusError || status == RunStatusCancelled
+}
+
+// Checks whether run finished successfully
+func (status RunStatus) IsSuccess() bool {
+	return status == RunStatusComplete
+}
+
+func (status RunStatus) String() string {
+	return string(status)
+}
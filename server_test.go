@@ -0,0 +1,109 @@
+package parsehub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// End time of finished runs in tests
+var testEndTime = time.Date(2019, 6, 24, 10, 0, 0, 0, time.UTC)
+
+// In-memory ParseHub API for tests. Close it after test.
+type fakeParseHub struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	runs     map[string]*RunResponse
+	sequence int
+	requests map[string]int
+}
+
+func newFakeParseHub() *fakeParseHub {
+	fake := &fakeParseHub{
+		runs:     map[string]*RunResponse{},
+		requests: map[string]int{},
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+
+	return fake
+}
+
+// Creates client pointed to fake server
+func (fake *fakeParseHub) client(opts ...Option) *ParseHub {
+	return NewParseHub("test-api-key", append([]Option{WithBaseUrl(fake.URL)}, opts...)...)
+}
+
+// Stores run returned by fake server
+func (fake *fakeParseHub) setRun(run RunResponse) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.runs[run.RunToken] = &run
+}
+
+// Returns number of requests with method and path
+func (fake *fakeParseHub) requestCount(method string, path string) int {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	return fake.requests[method+" "+path]
+}
+
+func (fake *fakeParseHub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.requests[r.Method+" "+r.URL.Path]++
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v2" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "projects":
+		writeJSON(w, ProjectsResponse{Projects: []*ProjectResponse{{Token: "project"}}})
+	case len(parts) == 3 && parts[1] == "projects":
+		writeJSON(w, ProjectResponse{Token: parts[2]})
+	case len(parts) == 4 && parts[1] == "projects" && parts[3] == "run":
+		fake.sequence++
+		run := &RunResponse{
+			ProjectToken: parts[2],
+			RunToken:     fmt.Sprintf("run-%d", fake.sequence),
+			Status:       "initialized",
+		}
+		fake.runs[run.RunToken] = run
+		writeJSON(w, run)
+	case len(parts) >= 3 && parts[1] == "runs":
+		run := fake.runs[parts[2]]
+		if run == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case len(parts) == 4 && parts[3] == "cancel":
+			run.Status = "cancelled"
+		case len(parts) == 4 && parts[3] == "data":
+			writeJSON(w, map[string]string{"run_token": run.RunToken})
+			return
+		case r.Method == http.MethodDelete:
+			delete(fake.runs, run.RunToken)
+		}
+
+		writeJSON(w, run)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,60 @@
+package parsehub
+
+import "time"
+
+// Immutable copy of run data.
+// Every update of run data creates new snapshot with greater version.
+type RunSnapshot struct {
+	RunResponse
+
+	// Version of run data, starts from 1 and grows with every update
+	Version uint64
+
+	// Time when run data was received
+	FetchedAt time.Time
+}
+
+// Immutable copy of project data.
+// Every update of project data creates new snapshot with greater version.
+type ProjectSnapshot struct {
+	ProjectResponse
+
+	// Version of project data, starts from 1 and grows with every update
+	Version uint64
+
+	// Time when project data was received
+	FetchedAt time.Time
+}
+
+// Returns copy of snapshot that can be modified by caller
+func (snapshot *RunSnapshot) clone() *RunSnapshot {
+	if snapshot == nil {
+		return nil
+	}
+
+	clone := *snapshot
+	clone.RunResponse = snapshot.RunResponse.clone()
+
+	return &clone
+}
+
+// Returns copy of snapshot that can be modified by caller
+func (snapshot *ProjectSnapshot) clone() *ProjectSnapshot {
+	if snapshot == nil {
+		return nil
+	}
+
+	clone := *snapshot
+
+	if snapshot.LastRun != nil {
+		lastRun := snapshot.LastRun.clone()
+		clone.LastRun = &lastRun
+	}
+
+	if snapshot.LastReadyRun != nil {
+		lastReadyRun := snapshot.LastReadyRun.clone()
+		clone.LastReadyRun = &lastReadyRun
+	}
+
+	return &clone
+}
@@ -0,0 +1,109 @@
+package parsehub
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRunSnapshotsConcurrentRefresh(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", ProjectToken: "project", Status: "running"})
+	parsehub := fake.client()
+
+	const goroutines = 20
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := parsehub.GetRun("run"); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			var version uint64
+			for j := 0; j < 10; j++ {
+				snapshot := NewRun(parsehub, "run").GetResponse()
+				if snapshot == nil {
+					continue
+				}
+
+				if snapshot.Version < version {
+					t.Errorf("version decreased from %d to %d", version, snapshot.Version)
+				}
+				version = snapshot.Version
+			}
+		}()
+	}
+	wg.Wait()
+
+	snapshot := NewRun(parsehub, "run").GetResponse()
+	if snapshot.Version != goroutines {
+		t.Fatalf("expected version %d, got %d", goroutines, snapshot.Version)
+	}
+
+	if snapshot.FetchedAt.IsZero() {
+		t.Fatal("expected fetch time of snapshot")
+	}
+}
+
+func TestRunSnapshotIsCopy(t *testing.T) {
+	run := NewRun(NewParseHub("test-api-key"), "run")
+	run.setResponse(&RunResponse{RunToken: "run", Status: "running"})
+
+	snapshot := run.GetResponse()
+	snapshot.Status = "complete"
+
+	if run.GetResponse().Status != "running" {
+		t.Fatal("modification of snapshot changed run data")
+	}
+}
+
+func TestProjectSnapshotIsDeepCopy(t *testing.T) {
+	project := NewProject(NewParseHub("test-api-key"), "project")
+	project.setResponse(&ProjectResponse{Token: "project", LastRun: &RunResponse{Status: "running"}})
+
+	snapshot := project.GetResponse()
+	snapshot.LastRun.Status = "complete"
+
+	if project.GetResponse().LastRun.Status != "running" {
+		t.Fatal("modification of snapshot changed project data")
+	}
+}
+
+func TestRunStartWatchingOnce(t *testing.T) {
+	run := NewRun(NewParseHub("test-api-key"), "run")
+
+	const goroutines = 50
+	started := make(chan bool, goroutines)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- run.startWatching()
+		}()
+	}
+	wg.Wait()
+	close(started)
+
+	count := 0
+	for ok := range started {
+		if ok {
+			count++
+		}
+	}
+
+	if count != 1 {
+		t.Fatalf("expected run to be watched once, got %d", count)
+	}
+}
@@ -0,0 +1,168 @@
+package parsehub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Template of project instructions decoded from templates_json
+type Template struct {
+	Name     string     `json:"name"`
+	Commands []*Command `json:"commands"`
+}
+
+// Command of template.
+// Known fields are decoded, all fields including undocumented ones are kept in Fields.
+type Command struct {
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Selection *Selection `json:"selection"`
+	Children  []*Command `json:"children"`
+
+	Fields map[string]json.RawMessage `json:"-"`
+}
+
+// Elements of page selected by command
+type Selection struct {
+	Selector string `json:"selector"`
+
+	Fields map[string]json.RawMessage `json:"-"`
+}
+
+// Project options decoded from options_json.
+// All options including undocumented ones are kept in Fields.
+type ProjectOptions struct {
+	// The template with which project starts executing
+	StartTemplate string `json:"start_template"`
+
+	// The url on which project starts running
+	StartURL string `json:"start_url"`
+
+	// The starting value of the global scope
+	StartValue map[string]interface{} `json:"-"`
+
+	Fields map[string]json.RawMessage `json:"-"`
+}
+
+func (command *Command) UnmarshalJSON(body []byte) error {
+	type plain Command
+	if err := json.Unmarshal(body, (*plain)(command)); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, &command.Fields)
+}
+
+func (selection *Selection) UnmarshalJSON(body []byte) error {
+	type plain Selection
+	if err := json.Unmarshal(body, (*plain)(selection)); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, &selection.Fields)
+}
+
+func (options *ProjectOptions) UnmarshalJSON(body []byte) error {
+	type plain ProjectOptions
+	if err := json.Unmarshal(body, (*plain)(options)); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, &options.Fields); err != nil {
+		return err
+	}
+
+	// start value is JSON-stringified object or object
+	startValue := options.Fields["start_value"]
+	if len(startValue) == 0 || string(startValue) == "null" {
+		return nil
+	}
+
+	if startValue[0] == '"' {
+		var value string
+		if err := json.Unmarshal(startValue, &value); err != nil {
+			return fmt.Errorf("start_value: %s", err)
+		}
+
+		var err error
+		options.StartValue, err = parseStartValue(value)
+		return err
+	}
+
+	if err := json.Unmarshal(startValue, &options.StartValue); err != nil {
+		return fmt.Errorf("start_value: %s", err)
+	}
+
+	return nil
+}
+
+// Decodes templates of TemplatesJSON.
+// Templates are accepted as list or as object keyed by template name.
+func (response ProjectResponse) Templates() ([]*Template, error) {
+	if response.TemplatesJSON == "" {
+		return nil, nil
+	}
+
+	body := []byte(response.TemplatesJSON)
+
+	var templates []*Template
+	if err := json.Unmarshal(body, &templates); err == nil {
+		return templates, nil
+	}
+
+	byName := map[string]*Template{}
+	if err := json.Unmarshal(body, &byName); err != nil {
+		return nil, &DecodeError{Endpoint: "templates_json", Body: response.TemplatesJSON, Err: err}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		template := byName[name]
+		if template.Name == "" {
+			template.Name = name
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+// Decodes options of OptionsJSON
+func (response ProjectResponse) Options() (*ProjectOptions, error) {
+	options := &ProjectOptions{}
+	if response.OptionsJSON == "" {
+		return options, nil
+	}
+
+	if err := json.Unmarshal([]byte(response.OptionsJSON), options); err != nil {
+		return nil, &DecodeError{Endpoint: "options_json", Body: response.OptionsJSON, Err: err}
+	}
+
+	return options, nil
+}
+
+// Returns decoded templates of project. Returns nil if project data was not loaded yet.
+func (p *Project) Templates() ([]*Template, error) {
+	snapshot := p.GetResponse()
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	return snapshot.Templates()
+}
+
+// Returns decoded options of project. Returns nil if project data was not loaded yet.
+func (p *Project) Options() (*ProjectOptions, error) {
+	snapshot := p.GetResponse()
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	return snapshot.Options()
+}
@@ -0,0 +1,103 @@
+package parsehub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProjectResponseOptions(t *testing.T) {
+	body := `{
+		"token": "project",
+		"options_json": "{\"start_template\": \"main_template\", \"start_url\": \"http://www.example.com\", \"start_value\": \"{\\\"query\\\": \\\"x\\\"}\", \"loadJs\": \"true\"}"
+	}`
+
+	response := &ProjectResponse{}
+	if err := json.Unmarshal([]byte(body), response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.OptionsJSON == "" {
+		t.Fatal("options_json is not decoded")
+	}
+
+	options, err := response.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.StartTemplate != "main_template" || options.StartURL != "http://www.example.com" || options.StartValue["query"] != "x" {
+		t.Fatalf("unexpected options %+v", options)
+	}
+
+	if string(options.Fields["loadJs"]) != `"true"` {
+		t.Fatalf("undocumented option is not kept, got %v", options.Fields)
+	}
+
+	if _, err := (ProjectResponse{OptionsJSON: "{"}).Options(); err == nil {
+		t.Fatal("expected error for malformed options")
+	}
+}
+
+func TestProjectResponseTemplates(t *testing.T) {
+	commands := `[{"type": "select", "name": "product", "selection": {"selector": "div.product", "nodes": 20},
+		"children": [{"type": "extract", "name": "price"}]}]`
+
+	tests := []struct {
+		name      string
+		templates string
+	}{
+		{"list", `[{"name": "main_template", "commands": ` + commands + `}]`},
+		{"object", `{"main_template": {"commands": ` + commands + `}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			templates, err := ProjectResponse{TemplatesJSON: test.templates}.Templates()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(templates) != 1 || templates[0].Name != "main_template" || len(templates[0].Commands) != 1 {
+				t.Fatalf("unexpected templates %+v", templates)
+			}
+
+			command := templates[0].Commands[0]
+			if command.Type != "select" || command.Selection == nil || command.Selection.Selector != "div.product" {
+				t.Fatalf("unexpected command %+v", command)
+			}
+
+			if string(command.Selection.Fields["nodes"]) != "20" {
+				t.Fatalf("undocumented field is not kept, got %v", command.Selection.Fields)
+			}
+
+			if len(command.Children) != 1 || command.Children[0].Name != "price" {
+				t.Fatalf("unexpected children %+v", command.Children)
+			}
+		})
+	}
+}
+
+func TestProjectTemplatesAndOptions(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+	project := NewProject(parsehub, "project")
+
+	if templates, err := project.Templates(); templates != nil || err != nil {
+		t.Fatalf("expected nil templates of not loaded project, got %v, %v", templates, err)
+	}
+
+	project.setResponse(&ProjectResponse{
+		Token:         "project",
+		TemplatesJSON: `[{"name": "main_template", "commands": []}]`,
+		OptionsJSON:   `{"start_template": "main_template"}`,
+	})
+
+	templates, err := project.Templates()
+	if err != nil || len(templates) != 1 {
+		t.Fatalf("unexpected templates %v, %v", templates, err)
+	}
+
+	options, err := project.Options()
+	if err != nil || options.StartTemplate != "main_template" {
+		t.Fatalf("unexpected options %+v, %v", options, err)
+	}
+}
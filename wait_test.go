@@ -0,0 +1,101 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunWaitReturnsCompleteRun(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "running"})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		fake.setRun(RunResponse{RunToken: "run", Status: "complete", EndTime: &testEndTime, Pages: 3})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response, err := NewRun(parsehub, "run").Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Status != "complete" || response.Pages != 3 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+}
+
+func TestRunWaitReturnsRunError(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "cancelled", EndTime: &testEndTime})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response, err := NewRun(parsehub, "run").Wait(ctx)
+	if !errors.Is(err, ErrRunCancelled) {
+		t.Fatalf("expected ErrRunCancelled, got %v", err)
+	}
+
+	if response == nil || response.Status != "cancelled" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+}
+
+func TestRunWaitRespectsDeadline(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "running"})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := NewRun(parsehub, "run").Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRunKeepsTerminalStatusOfOutOfOrderResponse(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	parsehub := fake.client()
+
+	// finished run is followed by stale response with earlier status
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusComplete})
+	run, err := parsehub.GetRun("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusRunning})
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := run.GetResponse().Status; status != RunStatusComplete {
+		t.Fatalf("finished run is updated with earlier status %s", status)
+	}
+
+	// second terminal status does not close terminated channel again
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusComplete, Pages: 3})
+	if _, err := parsehub.GetRun("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	if response, err := run.Wait(context.Background()); err != nil || response.Pages != 3 {
+		t.Fatalf("unexpected wait result %+v, %v", response, err)
+	}
+}
@@ -0,0 +1,216 @@
+package parsehub
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watched run persisted in WatchStore
+type WatchRecord struct {
+	RunToken     string            `json:"run_token"`
+	ProjectToken string            `json:"project_token"`
+	Handler      string            `json:"handler"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Guards       RunGuards         `json:"guards"`
+	StartedAt    time.Time         `json:"started_at"`
+}
+
+// Storage of runs watched with named handlers.
+// Records are saved when run is started and deleted when run is handled or not found.
+// Implementations must be safe for concurrent use.
+type WatchStore interface {
+	Save(record WatchRecord) error
+	Delete(runToken string) error
+	List() ([]WatchRecord, error)
+}
+
+// Set storage of runs watched with named handlers.
+// Unfinished runs of store are watched again when client is created.
+func WithWatchStore(store WatchStore) Option {
+	return func(parsehub *ParseHub) {
+		parsehub.watchStore = store
+	}
+}
+
+// Register handlers by name for Project.RunWithNamedHandler and resumed runs
+func WithNamedHandlers(name string, handlers RunHandlers) Option {
+	return func(parsehub *ParseHub) {
+		if parsehub.namedHandlers == nil {
+			parsehub.namedHandlers = map[string]RunHandlers{}
+		}
+		parsehub.namedHandlers[name] = handlers
+	}
+}
+
+// WatchStore keeping records in JSON file
+type FileWatchStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Creates new file watch store. File is created on first save.
+func NewFileWatchStore(path string) *FileWatchStore {
+	return &FileWatchStore{path: path}
+}
+
+func (store *FileWatchStore) Save(record WatchRecord) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	records, err := store.read()
+	if err != nil {
+		return err
+	}
+
+	records[record.RunToken] = record
+
+	return store.write(records)
+}
+
+func (store *FileWatchStore) Delete(runToken string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	records, err := store.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := records[runToken]; !ok {
+		return nil
+	}
+
+	delete(records, runToken)
+
+	return store.write(records)
+}
+
+// Returns records ordered by start time
+func (store *FileWatchStore) List() ([]WatchRecord, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	records, err := store.read()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]WatchRecord, 0, len(records))
+	for _, record := range records {
+		list = append(list, record)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+
+	return list, nil
+}
+
+func (store *FileWatchStore) read() (map[string]WatchRecord, error) {
+	records := map[string]WatchRecord{}
+
+	body, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) == 0 {
+		return records, nil
+	}
+
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Replaces file atomically so crash during write does not corrupt records
+func (store *FileWatchStore) write(records map[string]WatchRecord) error {
+	body, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), store.path)
+}
+
+// Returns handlers registered by name
+func (parsehub *ParseHub) namedHandler(name string) (RunHandlers, bool) {
+	handlers, ok := parsehub.namedHandlers[name]
+	return handlers, ok
+}
+
+// Saves record of run watched with named handler
+func (parsehub *ParseHub) saveWatch(record WatchRecord) {
+	if parsehub.watchStore == nil {
+		return
+	}
+
+	if err := parsehub.watchStore.Save(record); err != nil {
+		parsehub.logError("Save watch record error", "operation", OperationRunProject, "run_token", record.RunToken, "error", err)
+	}
+}
+
+// Deletes record of handled or lost run
+func (parsehub *ParseHub) deleteWatch(run *Run) {
+	if parsehub.watchStore == nil || run.getHandlerName() == "" {
+		return
+	}
+
+	if err := parsehub.watchStore.Delete(run.token); err != nil {
+		parsehub.logError("Delete watch record error", "operation", "Run.WatchAndHandle", "run_token", run.token, "error", err)
+	}
+}
+
+// Watches again unfinished runs of watch store
+func (parsehub *ParseHub) resumeWatches() {
+	records, err := parsehub.watchStore.List()
+	if err != nil {
+		parsehub.logError("List watch records error", "operation", "ParseHub.ResumeWatches", "error", err)
+		return
+	}
+
+	for _, record := range records {
+		handlers, ok := parsehub.namedHandler(record.Handler)
+		if !ok {
+			parsehub.logWarn("Resume run with unknown handler, skip", "operation", "ParseHub.ResumeWatches", "run_token", record.RunToken, "handler", record.Handler)
+			continue
+		}
+
+		run := NewRun(parsehub, record.RunToken)
+		run.SetHandlers(handlers)
+		run.setNamedHandler(record.Handler, record.Metadata)
+		run.SetGuards(record.Guards)
+		if !record.StartedAt.IsZero() {
+			run.setStartedAt(record.StartedAt)
+		}
+
+		parsehub.logDebug("Resume watching run", "operation", "ParseHub.ResumeWatches", "run_token", record.RunToken, "handler", record.Handler)
+		parsehub.watcher.watch(parsehub.watchContext, run)
+	}
+}
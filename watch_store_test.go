@@ -0,0 +1,254 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWatchStore(t *testing.T) (*FileWatchStore, func()) {
+	dir, err := ioutil.TempDir("", "parsehub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewFileWatchStore(filepath.Join(dir, "watches.json")), func() { os.RemoveAll(dir) }
+}
+
+func TestFileWatchStore(t *testing.T) {
+	store, cleanup := newTestWatchStore(t)
+	defer cleanup()
+
+	if records, err := store.List(); err != nil || len(records) != 0 {
+		t.Fatalf("expected empty store, got %v, %v", records, err)
+	}
+
+	started := time.Now()
+	for i, token := range []string{"second", "first"} {
+		record := WatchRecord{
+			RunToken:     token,
+			ProjectToken: "project",
+			Handler:      "export",
+			Metadata:     map[string]string{"tenant": "acme"},
+			StartedAt:    started.Add(-time.Duration(i) * time.Minute),
+		}
+		if err := store.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// records survive reopening of file
+	store = NewFileWatchStore(store.path)
+
+	records, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[0].RunToken != "first" || records[1].Metadata["tenant"] != "acme" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	if err := store.Delete("first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete("unknown"); err != nil {
+		t.Fatal(err)
+	}
+
+	if records, _ := store.List(); len(records) != 1 || records[0].RunToken != "second" {
+		t.Fatalf("unexpected records after delete %+v", records)
+	}
+}
+
+func TestRunWithNamedHandlerSavesAndDeletesRecord(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	store, cleanup := newTestWatchStore(t)
+	defer cleanup()
+
+	handled := make(chan map[string]string, 1)
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithWatchStore(store),
+		WithNamedHandlers("export", handlersOf(func(run *Run) error {
+			handled <- run.Metadata()
+			return nil
+		})),
+	)
+
+	project := NewProject(parsehub, "project")
+
+	if _, err := project.RunWithNamedHandler(context.Background(), ProjectRunParams{}, "unknown", nil); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("expected ErrInvalidParams for unknown handler, got %v", err)
+	}
+
+	params := ProjectRunParams{Guards: RunGuards{MaxPages: 100}}
+	run, err := project.RunWithNamedHandler(context.Background(), params, "export", map[string]string{"tenant": "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, _ := store.List()
+	if len(records) != 1 || records[0].RunToken != run.token || records[0].Handler != "export" || records[0].ProjectToken != "project" || records[0].Guards != params.Guards {
+		t.Fatalf("run is not saved to store, got %+v", records)
+	}
+
+	fake.setRun(RunResponse{RunToken: run.token, ProjectToken: "project", Status: "complete", DataReady: true})
+
+	select {
+	case metadata := <-handled:
+		if metadata["tenant"] != "acme" {
+			t.Fatalf("unexpected metadata %v", metadata)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler is not called")
+	}
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if records, _ := store.List(); len(records) != 0 {
+		t.Fatalf("handled run is not deleted from store, got %+v", records)
+	}
+}
+
+func TestWatchStoreResumedOnStart(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	store, cleanup := newTestWatchStore(t)
+	defer cleanup()
+
+	fake.setRun(RunResponse{RunToken: "run", ProjectToken: "project", Status: "complete", DataReady: true})
+	store.Save(WatchRecord{RunToken: "run", ProjectToken: "project", Handler: "export", StartedAt: time.Now()})
+	store.Save(WatchRecord{RunToken: "lost", ProjectToken: "project", Handler: "export", StartedAt: time.Now()})
+	store.Save(WatchRecord{RunToken: "other", ProjectToken: "project", Handler: "unknown", StartedAt: time.Now()})
+
+	handled := make(chan string, 2)
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithWatchStore(store),
+		WithNamedHandlers("export", handlersOf(func(run *Run) error {
+			handled <- run.token
+			return nil
+		})),
+	)
+
+	select {
+	case token := <-handled:
+		if token != "run" {
+			t.Fatalf("unexpected handled run %s", token)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("resumed run is not handled")
+	}
+
+	// handled and not found runs are deleted, run with unknown handler is kept
+	var records []WatchRecord
+	for i := 0; i < 100; i++ {
+		if records, _ = store.List(); len(records) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(records) != 1 || records[0].RunToken != "other" {
+		t.Fatalf("unexpected records after resume %+v", records)
+	}
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchStoreResumesGuards(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	store, cleanup := newTestWatchStore(t)
+	defer cleanup()
+
+	fake.setRun(RunResponse{RunToken: "run", ProjectToken: "project", Status: "running", Pages: 50})
+	store.Save(WatchRecord{
+		RunToken:     "run",
+		ProjectToken: "project",
+		Handler:      "export",
+		Guards:       RunGuards{MaxDuration: time.Hour, MaxPages: 10},
+		StartedAt:    time.Now(),
+	})
+
+	// guards survive reopening of file
+	if records, _ := NewFileWatchStore(store.path).List(); len(records) != 1 || records[0].Guards.MaxPages != 10 || records[0].Guards.MaxDuration != time.Hour {
+		t.Fatalf("guards are not persisted, got %+v", records)
+	}
+
+	tripped := make(chan string, 1)
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithWatchStore(store),
+		WithNamedHandlers("export", RunHandlers{
+			GuardTripped: func(run *Run, guardErr *GuardError) error {
+				tripped <- guardErr.Guard
+				return nil
+			},
+		}),
+	)
+
+	select {
+	case guard := <-tripped:
+		if guard != GuardMaxPages {
+			t.Fatalf("unexpected guard %s", guard)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("guard of resumed run is not tripped")
+	}
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchStoreDeletesRecordOfRunWithoutHandler(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	store, cleanup := newTestWatchStore(t)
+	defer cleanup()
+
+	// handler is set only for complete runs
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithWatchStore(store),
+		WithNamedHandlers("export", RunHandlers{Complete: func(run *Run) error { return nil }}),
+	)
+
+	run, err := NewProject(parsehub, "project").RunWithNamedHandler(context.Background(), ProjectRunParams{}, "export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.setRun(RunResponse{RunToken: run.token, ProjectToken: "project", Status: RunStatusError})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := run.Wait(ctx); !errors.Is(err, ErrRunFailed) {
+		t.Fatalf("expected ErrRunFailed, got %v", err)
+	}
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if records, _ := store.List(); len(records) != 0 {
+		t.Fatalf("finished run without handler is not deleted from store, got %+v", records)
+	}
+}
@@ -0,0 +1,538 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Default interval between polls of one watched run
+	DefaultPollInterval = 10 * time.Second
+
+	// Default number of concurrent polls
+	DefaultWatchWorkers = 4
+)
+
+// Set interval between polls of one watched run.
+// Polls of all watched runs are spread evenly over the interval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(parsehub *ParseHub) {
+		if interval > 0 {
+			parsehub.pollInterval = interval
+		}
+	}
+}
+
+// Set context of runs watched by Project.RunContext, Project.RunWithHandlers and resumed runs.
+// Watching stops and handlers are not called when context is done. Defaults to context.Background().
+// Context of start request does not bound watching, so started run is not orphaned by request timeout.
+func WithWatchContext(ctx context.Context) Option {
+	return func(parsehub *ParseHub) {
+		if ctx != nil {
+			parsehub.watchContext = ctx
+		}
+	}
+}
+
+// Set number of concurrent polls of watched runs
+func WithWatchWorkers(workers int) Option {
+	return func(parsehub *ParseHub) {
+		if workers > 0 {
+			parsehub.watchWorkers = workers
+		}
+	}
+}
+
+// Watched run
+type watch struct {
+	run *Run
+	ctx context.Context
+
+	// Set when run is finished and its handler is called
+	handling bool
+
+	// Closed when watching and handling are finished
+	done chan struct{}
+}
+
+// Result of watched run poll
+type pollResult struct {
+	watch    *watch
+	finished bool
+}
+
+// Scheduler of watched runs polling.
+// Runs are polled in round-robin order, one run per interval/len(runs),
+// by bounded pool of workers. Scheduler and workers are started with the first watch
+// and stopped when no run is watched.
+type watcher struct {
+	parsehub *ParseHub
+
+	results chan pollResult
+
+	mu      sync.Mutex
+	started bool
+	active  map[*watch]bool
+
+	// Number of watches sent to scheduler but not received yet
+	queued int
+
+	// Runs handled outside of watches, e.g. by webhook, LoadRunFromBytes or Replay
+	calls map[*Run]int
+
+	// Runs which watching was stopped by client close
+	abandoned []*Run
+
+	// Runs which watching was stopped by permanent error of ParseHub API
+	failed map[*Run]PendingRun
+
+	// Closed when client is closed and when no watch is active after close
+	closing chan struct{}
+	drained chan struct{}
+
+	// Closed when scheduler is stopped after close
+	stopped chan struct{}
+}
+
+func newWatcher(parsehub *ParseHub) *watcher {
+	return &watcher{
+		parsehub: parsehub,
+		results:  make(chan pollResult, parsehub.watchWorkers),
+		active:   map[*watch]bool{},
+		failed:   map[*Run]PendingRun{},
+		calls:    map[*Run]int{},
+		closing:  make(chan struct{}),
+		drained:  make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Adds run to scheduler. Returns nil if run is already watched or client is closed.
+func (w *watcher) watch(ctx context.Context, run *Run) *watch {
+	w.mu.Lock()
+
+	if w.isClosed() || !run.startWatching() {
+		w.mu.Unlock()
+		return nil
+	}
+
+	delete(w.failed, run)
+
+	if !w.started {
+		w.started = true
+
+		// buffered to accept polls while workers are starting
+		jobs := make(chan *watch, w.parsehub.watchWorkers)
+		for i := 0; i < w.parsehub.watchWorkers; i++ {
+			go w.work(jobs)
+		}
+		go w.schedule(jobs)
+	}
+
+	watch := &watch{
+		run:  run,
+		ctx:  ctx,
+		done: make(chan struct{}),
+	}
+	w.active[watch] = true
+	w.queued++
+	w.mu.Unlock()
+
+	select {
+	case w.parsehub.watchQueue <- watch:
+	case <-w.stopped:
+		// client was closed concurrently
+		w.abandon(watch)
+	}
+
+	return watch
+}
+
+// Checks whether client is closed
+func (w *watcher) isClosed() bool {
+	select {
+	case <-w.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stops accepting new watches and polling of watched runs. Returns false if already closed.
+func (w *watcher) close() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.isClosed() {
+		return false
+	}
+
+	close(w.closing)
+	if len(w.active) == 0 && len(w.calls) == 0 {
+		close(w.drained)
+	}
+	if !w.started {
+		close(w.stopped)
+	}
+
+	return true
+}
+
+// Returns runs abandoned by close and runs which are still watched or handled
+func (w *watcher) pending() []PendingRun {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := make([]PendingRun, 0, len(w.abandoned)+len(w.failed)+len(w.active)+len(w.calls))
+	for _, run := range w.abandoned {
+		pending = append(pending, pendingRunOf(run, false))
+	}
+	for _, failed := range w.failed {
+		pending = append(pending, failed)
+	}
+	for watch := range w.active {
+		pending = append(pending, pendingRunOf(watch.run, watch.handling))
+	}
+	for run := range w.calls {
+		pending = append(pending, pendingRunOf(run, true))
+	}
+
+	return pending
+}
+
+// Registers handler call outside of watch so close waits for it.
+// Returns false if client is closed.
+func (w *watcher) startCall(run *Run) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.isClosed() {
+		return false
+	}
+
+	w.calls[run]++
+	return true
+}
+
+// Removes finished handler call outside of watch and signals close
+func (w *watcher) finishCall(run *Run) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.calls[run]--; w.calls[run] <= 0 {
+		delete(w.calls, run)
+	}
+
+	if len(w.active) == 0 && len(w.calls) == 0 && w.isClosed() {
+		close(w.drained)
+	}
+}
+
+// Marks watch as handled
+func (w *watcher) startHandling(watch *watch) {
+	w.mu.Lock()
+	watch.handling = true
+	w.mu.Unlock()
+}
+
+// Removes finished watch and signals waiting callers
+func (w *watcher) finish(watch *watch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.active, watch)
+	close(watch.done)
+
+	if len(w.active) == 0 && len(w.calls) == 0 && w.isClosed() {
+		close(w.drained)
+	}
+}
+
+// Marks watch sent by watch method as received by scheduler
+func (w *watcher) received() {
+	w.mu.Lock()
+	w.queued--
+	w.mu.Unlock()
+}
+
+// Stops idle scheduler unless watch is being sent to it. Returns false if scheduler must keep running.
+func (w *watcher) idle() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.queued > 0 {
+		return false
+	}
+
+	w.started = false
+	if w.isClosed() {
+		close(w.stopped)
+	}
+
+	return true
+}
+
+// Dispatches polls of watched runs to workers until no run is watched
+func (w *watcher) schedule(jobs chan *watch) {
+	var (
+		ring    []*watch
+		next    int
+		polling = map[*watch]bool{}
+		timer   = time.NewTimer(w.parsehub.pollInterval)
+	)
+
+	defer timer.Stop()
+
+	remove := func(i int) {
+		ring = append(ring[:i], ring[i+1:]...)
+		if i < next {
+			next--
+		}
+		if next >= len(ring) {
+			next = 0
+		}
+	}
+
+	closing := w.closing
+
+	for {
+		if closing == nil && len(ring) == 0 {
+			// client is closed and all polls are finished
+			close(jobs)
+			close(w.stopped)
+			return
+		}
+
+		if len(ring) == 0 && w.idle() {
+			// no run is watched, scheduler is started again by next watch
+			close(jobs)
+			return
+		}
+
+		select {
+		case watch := <-w.parsehub.watchQueue:
+			w.received()
+
+			if closing == nil {
+				w.abandon(watch)
+				continue
+			}
+			ring = append(ring, watch)
+
+			// first watched run is polled without waiting for tick of idle timer
+			if len(ring) == 1 {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(0)
+			}
+
+		case <-closing:
+			// stop idle watches, polled ones are stopped when poll is finished
+			for i := len(ring) - 1; i >= 0; i-- {
+				if watch := ring[i]; !polling[watch] {
+					remove(i)
+					w.abandon(watch)
+				}
+			}
+			timer.Stop()
+			closing = nil
+
+		case result := <-w.results:
+			delete(polling, result.watch)
+
+			if !result.finished && closing == nil {
+				result.finished = true
+				w.abandon(result.watch)
+			}
+
+			if result.finished {
+				for i := range ring {
+					if ring[i] == result.watch {
+						remove(i)
+						break
+					}
+				}
+			}
+
+		case <-timer.C:
+			if closing == nil {
+				continue
+			}
+
+			// drop watches with done context
+			for i := len(ring) - 1; i >= 0; i-- {
+				if watch := ring[i]; watch.ctx.Err() != nil && !polling[watch] {
+					remove(i)
+					w.stop(watch, watch.ctx.Err())
+				}
+			}
+
+			if len(ring) > 0 {
+				watch := ring[next]
+
+				if !polling[watch] {
+					select {
+					case jobs <- watch:
+						polling[watch] = true
+						next = (next + 1) % len(ring)
+					default: // all workers are busy, try again on next tick
+					}
+				} else {
+					next = (next + 1) % len(ring)
+				}
+			}
+
+			delay := w.parsehub.pollInterval
+			if len(ring) > 1 {
+				delay /= time.Duration(len(ring))
+			}
+			timer.Reset(delay)
+		}
+	}
+}
+
+// Polls runs dispatched by scheduler
+func (w *watcher) work(jobs chan *watch) {
+	for watch := range jobs {
+		w.results <- pollResult{watch: watch, finished: w.poll(watch)}
+	}
+}
+
+// Refreshes run and starts handling of finished run. Returns true if watching is finished.
+// Failed polls are retried on next tick, watching stops on permanent API errors like ErrNotFound.
+func (w *watcher) poll(watch *watch) bool {
+	run := watch.run
+
+	w.parsehub.logDebug("Watch iteration", "operation", "Run.WatchAndHandle", "run_token", run.token)
+
+	// watched run is updated even if it is removed from registry
+	runResponse, err := w.parsehub.fetchRun(watch.ctx, run.token)
+	if err == nil {
+		run.setResponse(runResponse)
+	}
+
+	if watch.ctx.Err() != nil {
+		w.stop(watch, watch.ctx.Err())
+		return true
+	}
+
+	if isPermanentError(err) {
+		// run will never be found again, other errors may be fixed by new client configuration
+		if errors.Is(err, ErrNotFound) {
+			w.parsehub.deleteWatch(run)
+		}
+		w.fail(watch, err)
+		return true
+	}
+
+	if err != nil {
+		w.parsehub.logWarn("Poll run problem, retry on next tick", "operation", "Run.WatchAndHandle", "run_token", run.token, "error", err)
+		return false
+	}
+
+	snapshot := run.GetResponse()
+	if snapshot == nil {
+		return false
+	}
+
+	if snapshot.Status.IsTerminal() {
+		run.stopWatching()
+		w.startHandling(watch)
+		go w.handle(watch, snapshot, nil)
+		return true
+	}
+
+	guardErr := run.getGuards().check(snapshot, run.elapsed(snapshot))
+	if guardErr == nil {
+		return false
+	}
+
+	w.parsehub.logWarn("Guard tripped, cancel run", "operation", "Run.WatchAndHandle", "run_token", run.token, "error", guardErr)
+	if err := run.CancelContext(watch.ctx); err != nil {
+		w.parsehub.logWarn("Cancel run problem, retry on next tick", "operation", "Run.WatchAndHandle", "run_token", run.token, "error", err)
+		return false
+	}
+
+	run.stopWatching()
+	w.startHandling(watch)
+	go w.handle(watch, run.GetResponse(), guardErr)
+
+	return true
+}
+
+// Calls handler of finished run outcome or GuardTripped handler of run cancelled by guard
+func (w *watcher) handle(watch *watch, snapshot *RunSnapshot, guardErr *GuardError) {
+	defer w.finish(watch)
+
+	run := watch.run
+	handlers := run.getHandlers()
+	handleFunc := handlers.handler(snapshot)
+
+	if guardErr != nil && handlers.GuardTripped != nil {
+		handleFunc = func(run *Run) error {
+			return handlers.GuardTripped(run, guardErr)
+		}
+	}
+
+	if handleFunc == nil {
+		// finished run is not updated and resumed anymore
+		w.parsehub.logDebug("Watch finished, no handler", "operation", "Run.WatchAndHandle", "run_token", run.token, "status", snapshot.Status, "data_ready", snapshot.DataReady)
+		w.parsehub.runRegistry.delete(run)
+		w.parsehub.deleteWatch(run)
+		return
+	}
+
+	w.parsehub.logDebug("Watch finished, handle run", "operation", "Run.WatchAndHandle", "run_token", run.token, "status", snapshot.Status, "data_ready", snapshot.DataReady)
+	w.parsehub.callHandler(watch.ctx, "Run.WatchAndHandle", run, handleFunc)
+}
+
+// Stops watching of run by reason
+func (w *watcher) stop(watch *watch, reason error) {
+	watch.run.stopWatching()
+	w.parsehub.logWarn("Stop watching run", "operation", "Run.WatchAndHandle", "run_token", watch.run.token, "error", reason)
+	w.finish(watch)
+}
+
+// Stops watching of run by permanent error and keeps run for close report
+func (w *watcher) fail(watch *watch, err error) {
+	run := watch.run
+
+	w.mu.Lock()
+	failed := pendingRunOf(run, false)
+	failed.Err = err
+	w.failed[run] = failed
+	w.mu.Unlock()
+
+	run.failWatching(err)
+	w.parsehub.logError("Stop watching run on permanent error", "operation", "Run.WatchAndHandle", "run_token", run.token, "error", err)
+	w.finish(watch)
+}
+
+// Checks whether error is ParseHub API response which is not fixed by retry:
+// 4xx status other than 408 Request Timeout and 429 Too Many Requests
+func isPermanentError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 &&
+		apiErr.StatusCode != http.StatusRequestTimeout &&
+		apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+// Stops watching of run by client close and keeps run for close report
+func (w *watcher) abandon(watch *watch) {
+	w.mu.Lock()
+	w.abandoned = append(w.abandoned, watch.run)
+	w.mu.Unlock()
+
+	w.stop(watch, ErrClosed)
+}
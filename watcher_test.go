@@ -0,0 +1,336 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatcherHandlesRunsWithBoundedWorkers(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	var inFlight, maxInFlight int32
+	limiting := func(next RoundTripFunc) RoundTripFunc {
+		return func(op Operation, request *http.Request) (*http.Response, error) {
+			current := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return next(op, request)
+		}
+	}
+
+	parsehub := fake.client(
+		WithPollInterval(50*time.Millisecond),
+		WithWatchWorkers(2),
+		WithMiddleware(limiting),
+	)
+
+	const runs = 10
+	handled := make(chan string, runs)
+
+	for i := 0; i < runs; i++ {
+		token := fmt.Sprintf("run-%d", i)
+		fake.setRun(RunResponse{RunToken: token, Status: "running"})
+
+		run := NewRun(parsehub, token)
+		run.SetHandler(func(run *Run) error {
+			handled <- run.token
+			return nil
+		})
+		go run.WatchAndHandle()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < runs; i++ {
+		fake.setRun(RunResponse{RunToken: fmt.Sprintf("run-%d", i), Status: "complete", EndTime: &testEndTime})
+	}
+
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < runs; i++ {
+		select {
+		case <-handled:
+		case <-timeout:
+			t.Fatalf("handled %d of %d runs", i, runs)
+		}
+	}
+
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Fatalf("expected at most 2 concurrent polls, got %d", max)
+	}
+}
+
+func TestWatcherStopsWithContext(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "running"})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		t.Error("handler of unfinished run is called")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		run.WatchAndHandleContext(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watching is not stopped with context")
+	}
+
+	// run can be watched again after stop
+	if !run.startWatching() {
+		t.Fatal("run is still marked as watched")
+	}
+}
+
+func TestWatcherIgnoresDoubleWatch(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: "running"})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	run := NewRun(parsehub, "run")
+
+	if parsehub.watcher.watch(ctx, run) == nil {
+		t.Fatal("run is not watched")
+	}
+
+	if parsehub.watcher.watch(ctx, run) != nil {
+		t.Fatal("run is watched twice")
+	}
+}
+
+func TestRunContextWatchesAfterRequestContextIsDone(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	type key struct{}
+	watchCtx := context.WithValue(context.Background(), key{}, "watch")
+
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithWatchContext(watchCtx),
+	)
+
+	handled := make(chan interface{}, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	run, err := NewProject(parsehub, "project").RunContext(ctx, ProjectRunParams{}, func(run *Run) error {
+		handled <- run.Context().Value(key{})
+		return nil
+	})
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	fake.setRun(RunResponse{RunToken: run.token, ProjectToken: "project", Status: "complete", DataReady: true})
+
+	select {
+	case value := <-handled:
+		if value != "watch" {
+			t.Fatalf("handler context is not watch context, got %v", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("run is orphaned by done request context")
+	}
+}
+
+func TestWatchContextStopsWatching(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	parsehub := fake.client(
+		WithPollInterval(10*time.Millisecond),
+		WithWatchContext(watchCtx),
+	)
+
+	run, err := NewProject(parsehub, "project").Run(ProjectRunParams{}, func(run *Run) error {
+		t.Error("handler is called after watch context is done")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	time.Sleep(30 * time.Millisecond)
+
+	// finished run is not handled by stopped watch
+	fake.setRun(RunResponse{RunToken: run.token, ProjectToken: "project", Status: "complete", DataReady: true})
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherPollsFirstRunWithoutWaiting(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusComplete, DataReady: true})
+	parsehub := fake.client(WithPollInterval(time.Hour))
+
+	handled := make(chan struct{}, 1)
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		handled <- struct{}{}
+		return nil
+	})
+	go run.WatchAndHandle()
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("first watched run waits for poll interval")
+	}
+}
+
+func TestWatcherStopsWhenIdle(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	started := func() bool {
+		parsehub.watcher.mu.Lock()
+		defer parsehub.watcher.mu.Unlock()
+
+		return parsehub.watcher.started
+	}
+
+	for _, token := range []string{"first", "second"} {
+		fake.setRun(RunResponse{RunToken: token, Status: RunStatusRunning})
+
+		handled := make(chan struct{})
+		run := NewRun(parsehub, token)
+		run.SetHandler(func(run *Run) error {
+			close(handled)
+			return nil
+		})
+		go run.WatchAndHandle()
+
+		time.Sleep(30 * time.Millisecond)
+		if !started() {
+			t.Fatalf("scheduler is not running while %s run is watched", token)
+		}
+
+		fake.setRun(RunResponse{RunToken: token, Status: RunStatusComplete, DataReady: true})
+
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatalf("%s run is not handled", token)
+		}
+
+		for i := 0; i < 100 && started(); i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if started() {
+			t.Fatal("scheduler is not stopped without watched runs")
+		}
+	}
+
+	if _, err := parsehub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-parsehub.watcher.stopped:
+	default:
+		t.Fatal("stopped is not closed by close of idle watcher")
+	}
+}
+
+func TestWatcherStopsOnPermanentError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusNotFound, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.statusCode), func(t *testing.T) {
+			var requests int32
+			server := newStatusServer(&requests, test.statusCode)
+			defer server.Close()
+
+			parsehub := NewParseHub("test-api-key", WithBaseUrl(server.URL), WithPollInterval(10*time.Millisecond))
+
+			run := NewRun(parsehub, "run")
+			run.SetHandler(func(run *Run) error {
+				t.Error("handler of not polled run is called")
+				return nil
+			})
+
+			done := make(chan struct{})
+			go func() {
+				run.WatchAndHandle()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("watching is not stopped by permanent error")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			if _, err := run.Wait(ctx); !errors.Is(err, test.expected) {
+				t.Fatalf("expected Wait to return %v, got %v", test.expected, err)
+			}
+
+			// one poll of watch and one of wait
+			if count := atomic.LoadInt32(&requests); count != 2 {
+				t.Fatalf("expected 2 polls, got %d", count)
+			}
+
+			report, err := parsehub.Close(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(report.Pending) != 1 || report.Pending[0].RunToken != "run" || !errors.Is(report.Pending[0].Err, test.expected) {
+				t.Fatalf("failed run is not reported, got %+v", report.Pending)
+			}
+		})
+	}
+}
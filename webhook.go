@@ -0,0 +1,336 @@
+package parsehub
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Default limit of webhook request body
+	DefaultWebhookMaxBodyBytes = 1 << 20
+
+	// Default query parameter with webhook secret
+	DefaultWebhookSecretParam = "secret"
+)
+
+// Options of webhook handler
+type WebhookOptions struct {
+	// Handlers of runs by project token
+	Projects map[string]RunHandlers
+
+	// Handlers of runs of projects without own handlers
+	Handlers RunHandlers
+
+	// Limit of request body. Defaults to DefaultWebhookMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// Shared secret expected as last path segment or query parameter of webhook url.
+	// Requests without secret are rejected with 401. Empty secret disables check.
+	Secret string
+
+	// Query parameter with secret. Defaults to DefaultWebhookSecretParam.
+	SecretParam string
+
+	// Projects accepted by webhook. Runs of other projects are rejected with 403.
+	// Empty list accepts all projects.
+	AllowedProjects []string
+
+	// Re-fetch run with GetRun before dispatch and ignore run data of payload.
+	// Responds with 404 for unknown runs, 409 when finished status is not confirmed yet
+	// and 502 when ParseHub request fails.
+	Confirm bool
+}
+
+// Checks whether request url contains secret
+func (opts WebhookOptions) verify(request *http.Request) bool {
+	if opts.Secret == "" {
+		return true
+	}
+
+	path := strings.TrimSuffix(request.URL.Path, "/")
+	segment := path[strings.LastIndex(path, "/")+1:]
+
+	return secureEqual(segment, opts.Secret) || secureEqual(request.URL.Query().Get(opts.SecretParam), opts.Secret)
+}
+
+// Checks whether runs of project are accepted
+func (opts WebhookOptions) allowed(projectToken string) bool {
+	if len(opts.AllowedProjects) == 0 {
+		return true
+	}
+
+	for _, allowed := range opts.AllowedProjects {
+		if allowed == projectToken {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compares strings in constant time
+func secureEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Returns handlers for run of project
+func (opts WebhookOptions) handlers(projectToken string) RunHandlers {
+	if handlers, ok := opts.Projects[projectToken]; ok {
+		return handlers
+	}
+
+	return opts.Handlers
+}
+
+// Returns http.Handler of ParseHub webhook callbacks.
+// Accepts form-encoded and JSON run data, updates registered run and calls handler of finished run.
+// Handlers set on run, for example by Project.Run, have priority over handlers of options.
+// Runs which are not registered are not registered by webhook, finished runs without handler
+// are removed from registry.
+//
+// Responds with 200 when run is accepted, 400 for malformed payload, 401 for wrong secret,
+// 403 for not allowed project, 405 for non-POST requests, 413 for too large body,
+// 500 when handler fails all attempts of handler retry policy so ParseHub retries webhook
+// and 503 when client is closed. Failed run is put into dead letter queue until handler succeeds.
+func (parsehub *ParseHub) WebhookHandler(opts WebhookOptions) http.Handler {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultWebhookMaxBodyBytes
+	}
+
+	if opts.SecretParam == "" {
+		opts.SecretParam = DefaultWebhookSecretParam
+	}
+
+	parsehub.redactor.Add(opts.Secret)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if parsehub.watcher.isClosed() {
+			http.Error(w, ErrClosed.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		if !opts.verify(request) {
+			parsehub.logWarn("Webhook secret mismatch", "operation", "ParseHub.Webhook")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, request.Body, opts.MaxBodyBytes))
+		if err != nil {
+			parsehub.logWarn("Read webhook body error", "operation", "ParseHub.Webhook", "error", err)
+			if int64(len(body)) >= opts.MaxBodyBytes {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			} else {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			}
+			return
+		}
+
+		runResponse, err := decodeWebhook(request.Header.Get("Content-Type"), body)
+		if err != nil {
+			parsehub.logWarn("Unmarshal error", "operation", "ParseHub.Webhook", "body", body, "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !opts.allowed(runResponse.ProjectToken) {
+			parsehub.logWarn("Webhook project is not allowed", "operation", "ParseHub.Webhook", "project_token", runResponse.ProjectToken, "run_token", runResponse.RunToken)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if opts.Confirm {
+			// run data of payload is not trusted
+			confirmed, err := parsehub.fetchRun(request.Context(), runResponse.RunToken)
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+				return
+			}
+
+			if !opts.allowed(confirmed.ProjectToken) {
+				parsehub.logWarn("Webhook project is not allowed", "operation", "ParseHub.Webhook", "project_token", confirmed.ProjectToken, "run_token", confirmed.RunToken)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			// ParseHub retries webhook until run data is consistent
+			if runResponse.Status.IsTerminal() && !confirmed.Status.IsTerminal() {
+				parsehub.logWarn("Webhook status is not confirmed", "operation", "ParseHub.Webhook", "run_token", confirmed.RunToken, "status", runResponse.Status)
+				http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+				return
+			}
+
+			runResponse = confirmed
+		}
+
+		run := parsehub.webhookRun(runResponse, opts)
+		if run == nil {
+			parsehub.logDebug("Webhook run is not registered and has no handler", "operation", "ParseHub.Webhook", "run_token", runResponse.RunToken, "status", runResponse.Status)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := parsehub.handleWebhook(request.Context(), run, opts); errors.Is(err, ErrClosed) {
+			http.Error(w, ErrClosed.Error(), http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Updates registered run with webhook data
+func (parsehub *ParseHub) updateWebhookRun(runResponse *RunResponse) *Run {
+	run := NewRun(parsehub, runResponse.RunToken)
+	run.setResponse(runResponse)
+
+	return run
+}
+
+// Returns registered run updated with webhook data or new run which is not registered
+// if run is finished and options have its handler. Returns nil for other runs,
+// so webhooks of unknown runs do not grow registry.
+func (parsehub *ParseHub) webhookRun(runResponse *RunResponse, opts WebhookOptions) *Run {
+	if run := parsehub.runRegistry.get(runResponse.RunToken); run != nil {
+		run.setResponse(runResponse)
+		return run
+	}
+
+	if !runResponse.Status.IsTerminal() {
+		return nil
+	}
+
+	if opts.handlers(runResponse.ProjectToken).handler(&RunSnapshot{RunResponse: *runResponse}) == nil {
+		return nil
+	}
+
+	run := newRun(parsehub, runResponse.RunToken)
+	run.setResponse(runResponse)
+
+	return run
+}
+
+// Calls handler of finished run with client handler retry policy.
+// Failed handler is put into dead letter queue.
+func (parsehub *ParseHub) handleWebhook(ctx context.Context, run *Run, opts WebhookOptions) error {
+	snapshot := run.GetResponse()
+	parsehub.logDebug("Webhook run", "operation", "ParseHub.Webhook", "project_token", snapshot.ProjectToken, "run_token", run.token, "status", snapshot.Status)
+
+	if !snapshot.Status.IsTerminal() {
+		return nil
+	}
+
+	handlers := run.getHandlers()
+	if handlers.empty() {
+		handlers = opts.handlers(snapshot.ProjectToken)
+	}
+
+	handleFunc := handlers.handler(snapshot)
+	if handleFunc == nil {
+		// finished run is not updated anymore
+		parsehub.logDebug("Webhook run without handler", "operation", "ParseHub.Webhook", "run_token", run.token, "status", snapshot.Status)
+		parsehub.runRegistry.delete(run)
+		parsehub.deleteWatch(run)
+		return nil
+	}
+
+	// close waits for handler
+	if !parsehub.watcher.startCall(run) {
+		return ErrClosed
+	}
+	defer parsehub.watcher.finishCall(run)
+
+	// duplicated delivery or run handled by watcher is skipped
+	return parsehub.callHandler(ctx, "ParseHub.Webhook", run, handleFunc)
+}
+
+// Decodes run data of webhook body in JSON or form encoding
+func decodeWebhook(contentType string, body []byte) (*RunResponse, error) {
+	runResponse := &RunResponse{}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || (mediaType == "" && bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))) {
+		if err := decodeResponse("webhook", body, runResponse); err != nil {
+			return nil, err
+		}
+	} else {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, &DecodeError{Endpoint: "webhook", Body: string(body), Err: err}
+		}
+
+		if err := decodeRunForm(values, runResponse); err != nil {
+			return nil, &DecodeError{Endpoint: "webhook", Body: string(body), Err: err}
+		}
+	}
+
+	if runResponse.RunToken == "" {
+		return nil, &DecodeError{Endpoint: "webhook", Body: string(body), Err: errors.New("run_token is empty")}
+	}
+
+	return runResponse, nil
+}
+
+// Decodes run data of form fields named as JSON fields
+func decodeRunForm(values url.Values, runResponse *RunResponse) error {
+	runResponse.ProjectToken = values.Get("project_token")
+	runResponse.RunToken = values.Get("run_token")
+	runResponse.Status = RunStatus(values.Get("status"))
+	runResponse.Md5sum = values.Get("md5sum")
+	runResponse.StartURL = values.Get("start_url")
+	runResponse.StartTemplate = values.Get("start_template")
+
+	var err error
+
+	if runResponse.DataReady, err = parseDataReady(values.Get("data_ready")); err != nil {
+		return err
+	}
+
+	startTime := values.Get("start_time")
+	if parsed, err := parseTime(&startTime); err != nil {
+		return fmt.Errorf("start_time: %s", err)
+	} else if parsed != nil {
+		runResponse.StartTime = *parsed
+	}
+
+	endTime := values.Get("end_time")
+	if runResponse.EndTime, err = parseTime(&endTime); err != nil {
+		return fmt.Errorf("end_time: %s", err)
+	}
+
+	if runResponse.StartValue, err = parseStartValue(values.Get("start_value")); err != nil {
+		return err
+	}
+
+	if pages := values.Get("pages"); pages != "" {
+		value, err := strconv.ParseInt(pages, 10, 64)
+		if err != nil {
+			return fmt.Errorf("pages: %s", err)
+		}
+		runResponse.Pages = value
+	}
+
+	return nil
+}
@@ -0,0 +1,522 @@
+package parsehub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func postWebhook(handler http.Handler, contentType string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestWebhookHandlerRoutesRuns(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	var handled []string
+	handleAs := func(outcome string) HandleRunFunc {
+		return func(run *Run) error {
+			handled = append(handled, outcome+" "+run.token)
+			return nil
+		}
+	}
+
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Projects: map[string]RunHandlers{
+			"orders": {Complete: handleAs("orders complete"), Error: handleAs("orders error")},
+		},
+		Handlers: RunHandlers{Complete: handleAs("default complete")},
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"form", "application/x-www-form-urlencoded", "project_token=orders&run_token=r1&status=complete&data_ready=1&pages=3", "orders complete r1"},
+		{"form error", "application/x-www-form-urlencoded", "project_token=orders&run_token=r2&status=error&data_ready=true", "orders error r2"},
+		{"json", "application/json; charset=utf-8", `{"project_token":"other","run_token":"r3","status":"complete","data_ready":1}`, "default complete r3"},
+		{"json without content type", "", `{"project_token":"other","run_token":"r4","status":"complete","data_ready":1}`, "default complete r4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handled = nil
+
+			recorder := postWebhook(handler, test.contentType, test.body)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", recorder.Code)
+			}
+
+			if len(handled) != 1 || handled[0] != test.expected {
+				t.Fatalf("expected %q, got %v", test.expected, handled)
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerUpdatesRunningRun(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	called := false
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		called = true
+		return nil
+	})
+
+	handler := parsehub.WebhookHandler(WebhookOptions{})
+
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", "run_token=run&status=running&pages=7"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	if called {
+		t.Fatal("handler is called for running run")
+	}
+
+	if snapshot := run.GetResponse(); snapshot == nil || snapshot.Status != "running" || snapshot.Pages != 7 {
+		t.Fatalf("run is not updated, got %+v", snapshot)
+	}
+
+	// handler set on run has priority
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", "run_token=run&status=complete&data_ready=1"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	if !called {
+		t.Fatal("run handler is not called")
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatal("handled run is not removed from registry")
+	}
+}
+
+func TestWebhookHandlerStatusCodes(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Handlers: RunHandlers{
+			Complete: func(run *Run) error { return errors.New("storage unavailable") },
+			Error:    func(run *Run) error { panic("nil map") },
+		},
+		MaxBodyBytes: 128,
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"handler error", "application/x-www-form-urlencoded", "run_token=r1&status=complete&data_ready=1", http.StatusInternalServerError},
+		{"handler panic", "application/x-www-form-urlencoded", "run_token=r2&status=error&data_ready=1", http.StatusInternalServerError},
+		{"malformed json", "application/json", `{"run_token":`, http.StatusBadRequest},
+		{"malformed form", "application/x-www-form-urlencoded", "run_token=r3&status=complete&pages=many", http.StatusBadRequest},
+		{"missing run token", "application/x-www-form-urlencoded", "status=complete", http.StatusBadRequest},
+		{"too large body", "application/x-www-form-urlencoded", "run_token=" + strings.Repeat("r", 256), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if recorder := postWebhook(handler, test.contentType, test.body); recorder.Code != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, recorder.Code)
+			}
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != http.MethodPost {
+		t.Fatalf("expected 405 with Allow header, got %d", recorder.Code)
+	}
+
+	parsehub.Close(context.Background())
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", "run_token=r4&status=complete"); recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after close, got %d", recorder.Code)
+	}
+}
+
+func TestWebhookHandlerVerifiesSecretAndProject(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	calls := 0
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Handlers:        RunHandlers{Complete: func(run *Run) error { calls++; return nil }},
+		Secret:          "s3cret",
+		AllowedProjects: []string{"orders"},
+	})
+
+	post := func(target string, body string) int {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		expected int
+	}{
+		{"no secret", "/webhook", "project_token=orders&run_token=r1&status=complete&data_ready=1", http.StatusUnauthorized},
+		{"wrong secret", "/webhook?secret=guess", "project_token=orders&run_token=r1&status=complete&data_ready=1", http.StatusUnauthorized},
+		{"other project", "/webhook?secret=s3cret", "project_token=other&run_token=r1&status=complete&data_ready=1", http.StatusForbidden},
+		{"query secret", "/webhook?secret=s3cret", "project_token=orders&run_token=r1&status=complete&data_ready=1", http.StatusOK},
+		{"path secret", "/webhook/s3cret", "project_token=orders&run_token=r2&status=complete&data_ready=1", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := post(test.target, test.body); code != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, code)
+			}
+		})
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 handled runs, got %d", calls)
+	}
+}
+
+func TestWebhookHandlerConfirmsRun(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "running", ProjectToken: "orders", Status: "running"})
+	fake.setRun(RunResponse{RunToken: "done", ProjectToken: "orders", Status: "complete", DataReady: true})
+	fake.setRun(RunResponse{RunToken: "foreign", ProjectToken: "other", Status: "complete", DataReady: true})
+
+	parsehub := fake.client()
+
+	var handled []string
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Handlers: RunHandlers{
+			Complete: func(run *Run) error {
+				handled = append(handled, run.token+" "+run.GetResponse().Md5sum)
+				return nil
+			},
+		},
+		AllowedProjects: []string{"orders"},
+		Confirm:         true,
+	})
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"unknown run", "project_token=orders&run_token=forged&status=complete&data_ready=1", http.StatusNotFound},
+		{"forged status", "project_token=orders&run_token=running&status=complete&data_ready=1", http.StatusConflict},
+		{"forged project", "project_token=orders&run_token=foreign&status=complete&data_ready=1", http.StatusForbidden},
+		{"confirmed", "project_token=orders&run_token=done&status=complete&data_ready=1&md5sum=forged", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if recorder := postWebhook(handler, "application/x-www-form-urlencoded", test.body); recorder.Code != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, recorder.Code)
+			}
+		})
+	}
+
+	// handler gets fetched run data instead of payload
+	if len(handled) != 1 || handled[0] != "done " {
+		t.Fatalf("unexpected handled runs %v", handled)
+	}
+
+	if run := parsehub.runRegistry.get("running"); run != nil && run.GetResponse().Status != "running" {
+		t.Fatalf("forged status is applied, got %s", run.GetResponse().Status)
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatalf("webhook runs are left in registry, got %d", parsehub.runRegistry.len())
+	}
+}
+
+func TestWebhookHandlerDoesNotGrowRegistry(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	handled := 0
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Projects: map[string]RunHandlers{
+			"orders": {Complete: func(run *Run) error { handled++; return nil }},
+		},
+	})
+
+	for i := 0; i < 2000; i++ {
+		body := fmt.Sprintf("project_token=other&run_token=r%d&status=running", i)
+		switch i % 4 {
+		case 1:
+			body = fmt.Sprintf("project_token=other&run_token=r%d&status=complete&data_ready=1", i)
+		case 2:
+			body = fmt.Sprintf("project_token=orders&run_token=r%d&status=running", i)
+		case 3:
+			body = fmt.Sprintf("project_token=orders&run_token=r%d&status=complete&data_ready=1", i)
+		}
+
+		if recorder := postWebhook(handler, "application/x-www-form-urlencoded", body); recorder.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", recorder.Code)
+		}
+	}
+
+	if handled != 500 {
+		t.Fatalf("expected 500 handled runs, got %d", handled)
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatalf("webhooks grow registry to %d runs", parsehub.runRegistry.len())
+	}
+
+	// registered run without handler is dropped when finished
+	run := NewRun(parsehub, "registered")
+	postWebhook(handler, "application/x-www-form-urlencoded", "project_token=other&run_token=registered&status=running&pages=3")
+	if parsehub.runRegistry.get("registered") != run || run.GetResponse().Pages != 3 {
+		t.Fatal("registered run is not updated")
+	}
+
+	postWebhook(handler, "application/x-www-form-urlencoded", "project_token=other&run_token=registered&status=complete")
+	if parsehub.runRegistry.len() != 0 || run.GetResponse().Status != RunStatusComplete {
+		t.Fatal("finished run without handler is not dropped from registry")
+	}
+}
+
+func TestLoadRunFromBytes(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	run, err := parsehub.LoadRunFromBytes([]byte(`{"project_token":"project","run_token":"run","status":"running","pages":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run != NewRun(parsehub, "run") {
+		t.Fatal("loaded run is not registered")
+	}
+
+	if snapshot := run.GetResponse(); snapshot == nil || snapshot.ProjectToken != "project" || snapshot.Pages != 2 {
+		t.Fatalf("run response is not populated, got %+v", snapshot)
+	}
+
+	handled := 0
+	run.SetHandler(func(run *Run) error {
+		handled++
+		return nil
+	})
+
+	// registered run is reconciled and its handler is called
+	loaded, err := parsehub.LoadRunFromBytes([]byte("run_token=run&status=complete&data_ready=1&pages=5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded != run || run.GetResponse().Pages != 5 || handled != 1 {
+		t.Fatalf("run is not reconciled with registry, handled %d times", handled)
+	}
+
+	// stale payload does not overwrite finished run
+	parsehub.LoadRunFromBytes([]byte("run_token=run&status=running&pages=4"))
+	if snapshot := run.GetResponse(); snapshot.Status != "complete" || handled != 1 {
+		t.Fatalf("stale payload is applied, got %+v", snapshot)
+	}
+
+	if _, err := parsehub.LoadRunFromBytes([]byte(`{"run_token":`)); err == nil {
+		t.Fatal("expected error for malformed body")
+	}
+}
+
+func TestLoadRunFromBytesDoesNotGrowRegistry(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	for i := 0; i < 10; i++ {
+		body := fmt.Sprintf("run_token=run-%d&status=complete", i)
+		if _, err := parsehub.LoadRunFromBytes([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatalf("finished runs without handler are registered, got %d runs", parsehub.runRegistry.len())
+	}
+}
+
+func TestLoadRunFromBytesReturnsHandlerError(t *testing.T) {
+	parsehub := NewParseHub("test-api-key", WithHandlerRetryPolicy(HandlerRetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}))
+
+	errHandler := errors.New("export failed")
+	NewRun(parsehub, "run").SetHandler(func(run *Run) error { return errHandler })
+
+	run, err := parsehub.LoadRunFromBytes([]byte("run_token=run&status=complete"))
+	if !errors.Is(err, errHandler) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+
+	if run == nil || run.token != "run" {
+		t.Fatalf("run is not returned with handler error, got %v", run)
+	}
+
+	if letters := parsehub.DeadLetters(); len(letters) != 1 {
+		t.Fatalf("failed run is not put into dead letter queue, got %d letters", len(letters))
+	}
+}
+
+func TestLoadRunFromRequest(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	request := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("run_token=run&status=complete&data_ready=1"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	run, err := parsehub.LoadRunFromRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot := run.GetResponse(); snapshot.Status != "complete" || !snapshot.DataReady {
+		t.Fatalf("run response is not populated, got %+v", snapshot)
+	}
+
+	// form already parsed by router
+	request = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("run_token=parsed&status=error"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.ParseForm()
+
+	run, err = parsehub.LoadRunFromRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot := run.GetResponse(); run.token != "parsed" || snapshot.Status != "error" {
+		t.Fatalf("run response is not populated from parsed form, got %+v", snapshot)
+	}
+}
+
+func TestWebhookHandlerUsesHandlerRetryPolicy(t *testing.T) {
+	var letters int32
+	parsehub := NewParseHub("test-api-key",
+		WithHandlerRetryPolicy(HandlerRetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}),
+		WithDeadLetterFunc(func(letter *DeadLetter) { atomic.AddInt32(&letters, 1) }),
+	)
+
+	var calls int32
+	fail := true
+	handler := parsehub.WebhookHandler(WebhookOptions{
+		Handlers: RunHandlers{Complete: func(run *Run) error {
+			atomic.AddInt32(&calls, 1)
+			if fail {
+				return errors.New("storage unavailable")
+			}
+			return nil
+		}},
+	})
+
+	body := "run_token=run&status=complete&data_ready=1"
+
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", body); recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+
+	if calls != 3 || letters != 1 || len(parsehub.DeadLetters()) != 1 {
+		t.Fatalf("expected 3 calls and dead letter, got %d calls and %d letters", calls, letters)
+	}
+
+	// redelivered webhook is handled and dead letter is removed
+	fail = false
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", body); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	if len(parsehub.DeadLetters()) != 0 {
+		t.Fatal("dead letter of handled run is not removed")
+	}
+}
+
+func TestWatchedRunHandledByWebhookIsNotRegisteredAgain(t *testing.T) {
+	fake := newFakeParseHub()
+	defer fake.Close()
+
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusRunning})
+	parsehub := fake.client(WithPollInterval(10 * time.Millisecond))
+
+	var calls int32
+	run := NewRun(parsehub, "run")
+	run.SetHandler(func(run *Run) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		run.WatchAndHandle()
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+
+	handler := parsehub.WebhookHandler(WebhookOptions{})
+	if recorder := postWebhook(handler, "application/x-www-form-urlencoded", "run_token=run&status=complete&data_ready=1"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	// next poll finishes watch of run handled by webhook
+	fake.setRun(RunResponse{RunToken: "run", Status: RunStatusComplete, DataReady: true})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watch is not finished")
+	}
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected 1 handler call, got %d", calls)
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatal("polled run is registered again after webhook handled it")
+	}
+}
+
+func TestLoadRunFromRequestRejectsTooLargeBody(t *testing.T) {
+	parsehub := NewParseHub("test-api-key")
+
+	// valid form cut at limit would be accepted without error
+	body := "run_token=run&status=complete&pages=" + strings.Repeat("1", DefaultWebhookMaxBodyBytes)
+	request := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := parsehub.LoadRunFromRequest(request); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected ErrBodyTooLarge, got %v", err)
+	}
+
+	if parsehub.runRegistry.len() != 0 {
+		t.Fatal("run of too large body is registered")
+	}
+
+	// body of exactly limit size is accepted
+	body = "run_token=run&status=running&md5sum="
+	body += strings.Repeat("a", DefaultWebhookMaxBodyBytes-len(body))
+	request = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := parsehub.LoadRunFromRequest(request); err != nil {
+		t.Fatalf("unexpected error for body at limit: %v", err)
+	}
+}